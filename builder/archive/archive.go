@@ -2,9 +2,13 @@ package archive
 
 import (
 	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"path"
+	"sort"
 
 	"github.com/go-git/go-billy/v5"
 
@@ -18,14 +22,45 @@ var _ builder.Builder = (*ArchiveBuilder)(nil)
 type ArchiveBuilder struct {
 	Downloader *fetcher.Fetcher
 	Archive    *zip.Writer
+
+	// Template is the data for files with "template" action.
+	Template builder.TemplateData
 }
 
 func NewArchiveBuilder(dl *fetcher.Fetcher, w *zip.Writer) *ArchiveBuilder {
-	return &ArchiveBuilder{dl, w}
+	return &ArchiveBuilder{
+		Downloader: dl,
+		Archive:    w,
+	}
 }
 
-func (b *ArchiveBuilder) Add(m modpacker.Mod) error {
-	src, err := b.Downloader.Open(m)
+func (b *ArchiveBuilder) Add(ctx context.Context, m modpacker.Mod) error {
+	// Archive has no way to mark files as optional,
+	// so we add only the mods that were selected.
+	if m.Optional {
+		return nil
+	}
+	// Methods that fetch directories may also fetch files,
+	// e.g. "git", so we fall back to Open for non-directories.
+	fs, err := b.Downloader.OpenDir(ctx, m)
+	switch {
+	case err == nil:
+		if m.Action != modpacker.ActionNone {
+			return builder.ErrUnknownModAction
+		}
+		filter := builder.Filter{
+			Include: m.Include,
+			Exclude: m.Exclude,
+		}
+		return b.AddDir(fs, "", m.Path, filter)
+	case !errors.Is(err, fetcher.ErrNotDir):
+		return err
+	}
+	patcher, err := builder.ReadPatches(m.Patches)
+	if err != nil {
+		return err
+	}
+	src, err := b.Downloader.Open(ctx, m)
 	if err != nil {
 		return err
 	}
@@ -39,12 +74,74 @@ func (b *ArchiveBuilder) Add(m modpacker.Mod) error {
 	case modpacker.ActionNone:
 		return b.AddReader(src, m.Path)
 	case modpacker.ActionUnzip:
-		return b.AddUnzip(src, m.Path)
+		e := builder.NewExtract(m)
+		e.Patcher = patcher
+		return b.AddUnzip(src, m.Path, e)
+	case modpacker.ActionUntar:
+		e := builder.NewExtract(m)
+		e.Patcher = patcher
+		return b.AddUntar(src, m.Path, e)
+	case modpacker.ActionExtract:
+		return b.AddMember(src, m.Member, m.Path)
+	case modpacker.ActionTemplate:
+		return b.AddTemplate(src, m.Path)
+	case modpacker.ActionPatch:
+		r, err := patcher.PatchAll(src)
+		if err != nil {
+			return err
+		}
+		return b.AddReader(r, m.Path)
 	}
 	return builder.ErrUnknownModAction
 }
 
-func (b *ArchiveBuilder) AddUnzip(f billy.File, dir string) error {
+// AddDir adds files from directory dir of the filesystem to the archive
+// directory name. Files are added in lexical order.
+func (b *ArchiveBuilder) AddDir(fs billy.Filesystem, dir, name string, filter builder.Filter) error {
+	fis, err := fs.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	sort.Slice(fis, func(i, j int) bool {
+		return fis[i].Name() < fis[j].Name()
+	})
+	for _, fi := range fis {
+		rel := path.Join(dir, fi.Name())
+		if fi.IsDir() {
+			if filter.Excluded(rel) {
+				continue
+			}
+			if err := b.AddDir(fs, rel, name, filter); err != nil {
+				return err
+			}
+			continue
+		}
+		if !filter.Match(rel) {
+			continue
+		}
+		if err := b.AddFile(fs, rel, path.Join(name, rel)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddFile adds the file fpath from filesystem to the archive.
+func (b *ArchiveBuilder) AddFile(fs billy.Filesystem, fpath, name string) error {
+	f, err := fs.Open(fpath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := f.Close()
+		if err != nil {
+			log.Printf("close: %+v", err)
+		}
+	}()
+	return b.AddReader(f, name)
+}
+
+func (b *ArchiveBuilder) AddUnzip(f billy.File, dir string, e builder.Extract) error {
 	fi, err := billy.Stat(f)
 	if err != nil {
 		return err
@@ -59,20 +156,45 @@ func (b *ArchiveBuilder) AddUnzip(f billy.File, dir string) error {
 		// If last char in file name is slash,
 		// then the file is empty and represents
 		// a directory. We skip those for brevity.
-		l := len(f.Name)
-		if l > 0 {
-			if f.Name[l-1] == '/' {
-				continue
-			}
+		if f.FileInfo().IsDir() {
+			continue
 		}
-		// TODO should we sanitize name?
-		name := path.Join(dir, f.Name)
-		err := b.AddZipFile(f, name)
+		rel, ok, err := e.Name(f.Name)
 		if err != nil {
+			return fmt.Errorf("%q: %w", f.Name, err)
+		}
+		if !ok {
+			continue
+		}
+		if err := b.addZipMember(f, dir, rel, e); err != nil {
 			return err
 		}
 	}
-	return nil
+	return e.Patcher.Check()
+}
+
+func (b *ArchiveBuilder) addZipMember(f *zip.File, dir, rel string, e builder.Extract) error {
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := r.Close()
+		if err != nil {
+			log.Printf("close %q: %+v", f.Name, err)
+		}
+	}()
+	return b.addMember(r, dir, rel, e)
+}
+
+// addMember adds extracted file rel to the archive directory dir,
+// applying the patches for the file.
+func (b *ArchiveBuilder) addMember(r io.Reader, dir, rel string, e builder.Extract) error {
+	r, err := e.Patcher.Patch(r, rel)
+	if err != nil {
+		return err
+	}
+	return b.AddReader(r, path.Join(dir, rel))
 }
 
 func (b *ArchiveBuilder) AddZipFile(f *zip.File, name string) error {
@@ -80,6 +202,12 @@ func (b *ArchiveBuilder) AddZipFile(f *zip.File, name string) error {
 	if err != nil {
 		return err
 	}
+	defer func() {
+		err := r.Close()
+		if err != nil {
+			log.Printf("close %q: %+v", f.Name, err)
+		}
+	}()
 	return b.AddReader(r, name)
 }
 
@@ -92,6 +220,16 @@ func (b *ArchiveBuilder) AddReader(r io.Reader, name string) error {
 	return err
 }
 
+// AddTemplate renders the template read from r with the builder's
+// template data and adds the result to the archive.
+func (b *ArchiveBuilder) AddTemplate(r io.Reader, name string) error {
+	w, err := b.Archive.Create(name)
+	if err != nil {
+		return err
+	}
+	return b.Template.Render(w, r, name)
+}
+
 func (b *ArchiveBuilder) Close() error {
 	return nil
 }
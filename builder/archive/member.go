@@ -0,0 +1,73 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/go-git/go-billy/v5"
+
+	"github.com/tie/modpacker/builder"
+)
+
+var zipMagic = []byte("PK\x03\x04")
+
+// errFound stops archive iteration once the member is found.
+var errFound = errors.New("member found")
+
+// AddMember adds a single member of zip or tar archive as file name.
+// Tar archives may be compressed.
+func (b *ArchiveBuilder) AddMember(f billy.File, member, name string) error {
+	want, err := builder.CleanName(member)
+	if err != nil {
+		return fmt.Errorf("%q: %w", member, err)
+	}
+
+	magic := make([]byte, len(zipMagic))
+	if _, err := io.ReadFull(f, magic); err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if bytes.Equal(magic, zipMagic) {
+		fi, err := billy.Stat(f)
+		if err != nil {
+			return err
+		}
+		z, err := zip.NewReader(f, fi.Size())
+		if err != nil {
+			return err
+		}
+		for _, zf := range z.File {
+			if zf.FileInfo().IsDir() {
+				continue
+			}
+			if n, err := builder.CleanName(zf.Name); err != nil || n != want {
+				continue
+			}
+			return b.AddZipFile(zf, name)
+		}
+	} else {
+		err := walkTar(f, func(h *tar.Header, r io.Reader) error {
+			if n, err := builder.CleanName(h.Name); err != nil || n != want {
+				return nil
+			}
+			if err := b.AddReader(r, name); err != nil {
+				return err
+			}
+			return errFound
+		})
+		if err == errFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("%q: %w", member, builder.ErrMemberNotFound)
+}
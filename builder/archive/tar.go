@@ -0,0 +1,101 @@
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+
+	"github.com/tie/modpacker/builder"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	xzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// AddUntar adds regular files from tar archive to the directory dir.
+// The archive may be compressed with gzip, bzip2, xz or zstd.
+func (b *ArchiveBuilder) AddUntar(r io.Reader, dir string, e builder.Extract) error {
+	err := walkTar(r, func(h *tar.Header, r io.Reader) error {
+		rel, ok, err := e.Name(h.Name)
+		if err != nil {
+			return fmt.Errorf("%q: %w", h.Name, err)
+		}
+		if !ok {
+			return nil
+		}
+		return b.addMember(r, dir, rel, e)
+	})
+	if err != nil {
+		return err
+	}
+	return e.Patcher.Check()
+}
+
+// walkTar calls fn for each regular file in the tar archive.
+func walkTar(r io.Reader, fn func(h *tar.Header, r io.Reader) error) error {
+	dr, err := decompress(r)
+	if err != nil {
+		return err
+	}
+	defer dr.Close()
+
+	t := tar.NewReader(dr)
+	for {
+		h, err := t.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// Zip archives have no links, so we add only regular
+		// files, skipping directories like unzip does.
+		switch h.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+		default:
+			continue
+		}
+		if err := fn(h, t); err != nil {
+			return err
+		}
+	}
+}
+
+// decompress detects compression format of r and returns
+// the decompressed stream.
+func decompress(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	// Peek returns an error if the stream is shorter than magic,
+	// in that case it is not compressed.
+	magic, _ := br.Peek(len(xzMagic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return ioutil.NopCloser(bzip2.NewReader(br)), nil
+	case bytes.HasPrefix(magic, xzMagic):
+		xr, err := xz.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(xr), nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	}
+	return ioutil.NopCloser(br), nil
+}
@@ -1,6 +1,7 @@
 package builder
 
 import (
+	"context"
 	"errors"
 
 	"github.com/tie/modpacker/modpacker"
@@ -9,6 +10,6 @@ import (
 var ErrUnknownModAction = errors.New("unknown mod action")
 
 type Builder interface {
-	Add(m modpacker.Mod) error
+	Add(ctx context.Context, m modpacker.Mod) error
 	Close() error
 }
@@ -3,6 +3,7 @@ package curse
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"encoding/json"
 	"path"
 
@@ -15,21 +16,29 @@ import (
 
 var _ builder.Builder = (*CurseBuilder)(nil)
 
+// overridesDir is the directory in modpack archive
+// containing files that are not downloaded by launcher.
+const overridesDir = "overrides"
+
 type CurseBuilder struct {
 	archive.ArchiveBuilder
 
+	Modpack    modpacker.Modpack
 	CurseFiles []jsonspec.File
 }
 
-func NewCurseBuilder(dl *fetcher.Fetcher, w *zip.Writer) *CurseBuilder {
-	b := archive.ArchiveBuilder{dl, w}
-	return &CurseBuilder{b, nil}
+func NewCurseBuilder(dl *fetcher.Fetcher, w *zip.Writer, mp modpacker.Modpack) *CurseBuilder {
+	b := archive.NewArchiveBuilder(dl, w)
+	return &CurseBuilder{
+		ArchiveBuilder: *b,
+		Modpack:        mp,
+	}
 }
 
-func (b *CurseBuilder) Add(m modpacker.Mod) error {
+func (b *CurseBuilder) Add(ctx context.Context, m modpacker.Mod) error {
 	if m.Method != modpacker.MethodCurse || m.Action != modpacker.ActionNone {
-		m.Path = path.Join("overrides", m.Path)
-		return b.ArchiveBuilder.Add(m)
+		m.Path = path.Join(overridesDir, m.Path)
+		return b.ArchiveBuilder.Add(ctx, m)
 	}
 
 	// FIXME How does Curse handle manifests with other modpack projects files?
@@ -38,30 +47,36 @@ func (b *CurseBuilder) Add(m modpacker.Mod) error {
 	b.CurseFiles = append(b.CurseFiles, jsonspec.File{
 		ProjectID: m.ProjectID,
 		FileID:    m.FileID,
-		Required:  true,
+		Required:  !m.Optional,
 	})
 	return nil
 }
 
 func (b *CurseBuilder) Close() error {
-	// TODO so many things to do.
+	mp := b.Modpack
+	files := b.CurseFiles
+	if files == nil {
+		// Launcher expects an array.
+		files = []jsonspec.File{}
+	}
 	m := jsonspec.Manifest{
 		Minecraft: jsonspec.MinecraftInstance{
-			// TODO
-			Version: "",
+			Version: mp.Minecraft,
 			ModLoaders: []jsonspec.ModLoader{
-				// TODO
-				jsonspec.ModLoader{},
+				{
+					ID:      mp.Loader,
+					Primary: true,
+				},
 			},
 		},
 		ManifestType:    "minecraftModpack",
 		ManifestVersion: 1,
-		Name:            "", // TODO
-		Version:         "", // TODO
-		Author:          "", // TODO
-		Desc:            "", // TODO
-		ProjectID:       0,  // TODO
-		Files:           b.CurseFiles,
+		Name:            mp.Name,
+		Version:         mp.Version,
+		Author:          mp.Author,
+		Desc:            mp.Description,
+		Files:           files,
+		Overrides:       overridesDir,
 	}
 	var buf bytes.Buffer
 	if err := json.NewEncoder(&buf).Encode(&m); err != nil {
@@ -0,0 +1,88 @@
+package builder
+
+import (
+	"errors"
+	"path"
+	"strings"
+
+	"github.com/tie/modpacker/modpacker"
+)
+
+var (
+	ErrUnsafePath     = errors.New("unsafe path in archive")
+	ErrMemberNotFound = errors.New("archive member not found")
+)
+
+// Extract maps names of archive members to names relative
+// to the extraction directory.
+type Extract struct {
+	// Strip is the number of leading path elements to remove.
+	Strip int
+	// Subdir is the archive directory to extract.
+	// It is applied after Strip.
+	Subdir string
+	// Filter selects the members by their mapped names.
+	Filter Filter
+	// Patcher patches the extracted files by their mapped names.
+	Patcher *Patcher
+}
+
+// NewExtract returns Extract for the mod with unzip or untar action.
+func NewExtract(m modpacker.Mod) Extract {
+	return Extract{
+		Strip:  m.Strip,
+		Subdir: m.Subdir,
+		Filter: Filter{
+			Include: m.Include,
+			Exclude: m.Exclude,
+		},
+	}
+}
+
+// Name returns the name of archive member relative to the extraction
+// directory. It returns false if the member should not be extracted.
+// See CleanName for the errors.
+func (e Extract) Name(member string) (string, bool, error) {
+	name, err := CleanName(member)
+	if err != nil {
+		return "", false, err
+	}
+	if name == "" {
+		return "", false, nil
+	}
+
+	if e.Strip > 0 {
+		elems := strings.SplitN(name, "/", e.Strip+1)
+		if len(elems) <= e.Strip {
+			return "", false, nil
+		}
+		name = elems[e.Strip]
+	}
+
+	if dir := strings.Trim(path.Clean(e.Subdir), "/"); dir != "" && dir != "." {
+		if !strings.HasPrefix(name, dir+"/") {
+			return "", false, nil
+		}
+		name = name[len(dir)+1:]
+	}
+
+	if !e.Filter.Match(name) {
+		return "", false, nil
+	}
+	return name, true, nil
+}
+
+// CleanName returns the canonical slash-separated name of archive member
+// relative to the archive root. Member names that refer to the parent
+// directories are rejected with ErrUnsafePath error.
+func CleanName(member string) (string, error) {
+	// Some archivers use backslashes as the path separator.
+	name := strings.Replace(member, "\\", "/", -1)
+	for _, elem := range strings.Split(name, "/") {
+		if elem == ".." {
+			return "", ErrUnsafePath
+		}
+	}
+	// Absolute names are treated as relative to the archive root.
+	return path.Clean("/" + name)[1:], nil
+}
@@ -0,0 +1,70 @@
+package builder
+
+import (
+	"path"
+	"strings"
+)
+
+// Filter selects files by their slash-separated relative names.
+//
+// A pattern without slash is matched against each element of the name,
+// so that "*.bak" matches "a/b.bak" and "__MACOSX" matches all files in
+// that directory. A pattern with slash is matched against the leading
+// elements of the name, e.g. "World/region" matches all files in that
+// directory, and "/config" matches only the top-level directory. Patterns
+// use path.Match syntax.
+type Filter struct {
+	// Include is a list of patterns for files to include.
+	// If empty, all files are included.
+	Include []string
+	// Exclude is a list of patterns for files to exclude.
+	Exclude []string
+}
+
+// Match reports whether the file is selected by filter.
+func (f Filter) Match(name string) bool {
+	return f.Included(name) && !f.Excluded(name)
+}
+
+// Included reports whether name matches any of the include patterns.
+func (f Filter) Included(name string) bool {
+	if len(f.Include) <= 0 {
+		return true
+	}
+	return matchAny(f.Include, name)
+}
+
+// Excluded reports whether name matches any of the exclude patterns.
+// Unlike Match, it can be used to skip directories.
+func (f Filter) Excluded(name string) bool {
+	return matchAny(f.Exclude, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	elems := strings.Split(name, "/")
+	for _, pattern := range patterns {
+		if matchPattern(pattern, elems) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPattern(pattern string, elems []string) bool {
+	pattern = strings.TrimSuffix(pattern, "/")
+	if !strings.Contains(pattern, "/") {
+		for _, elem := range elems {
+			if ok, _ := path.Match(pattern, elem); ok {
+				return true
+			}
+		}
+		return false
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+	n := strings.Count(pattern, "/") + 1
+	if n > len(elems) {
+		return false
+	}
+	ok, _ := path.Match(pattern, strings.Join(elems[:n], "/"))
+	return ok
+}
@@ -0,0 +1,267 @@
+// Package patch parses and applies unified diffs.
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrMalformed = errors.New("malformed diff")
+	ErrNoHunks   = errors.New("diff has no hunks")
+)
+
+const devNull = "/dev/null"
+
+// File is a diff of a single file.
+type File struct {
+	OldName string
+	NewName string
+	Hunks   []Hunk
+}
+
+// Hunk is a contiguous group of changes. Lines include the prefix
+// character (' ', '-' or '+') and the line terminator, if any.
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Lines              []string
+}
+
+// HunkError is returned when a hunk does not apply to the file.
+type HunkError struct {
+	// Hunk is the one-based hunk number in the file diff.
+	Hunk int
+	// Line is the one-based line number in the original file.
+	Line int
+	Want string
+	Got  string
+	EOF  bool
+}
+
+func (e *HunkError) Error() string {
+	if e.EOF {
+		return fmt.Sprintf("hunk #%d at line %d: expected %q, found end of file", e.Hunk, e.Line, e.Want)
+	}
+	return fmt.Sprintf("hunk #%d at line %d: expected %q, found %q", e.Hunk, e.Line, e.Want, e.Got)
+}
+
+// Name returns the name of the patched file relative to the tree root.
+// A leading "a/" or "b/" element added by git and diff is removed.
+func (f *File) Name() string {
+	name := f.NewName
+	if name == devNull {
+		name = f.OldName
+	}
+	for _, prefix := range []string{"a/", "b/"} {
+		if strings.HasPrefix(name, prefix) {
+			return name[len(prefix):]
+		}
+	}
+	return name
+}
+
+// Parse parses file diffs from unified diff. Lines outside of file
+// diffs, e.g. git extended headers, are ignored.
+func Parse(data []byte) ([]*File, error) {
+	lines := splitLines(data)
+
+	var files []*File
+	var f *File
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			f = &File{
+				OldName: headerName(line[4:]),
+				NewName: headerName(lines[i+1][4:]),
+			}
+			files = append(files, f)
+			i++
+		case strings.HasPrefix(line, "@@ "):
+			if f == nil {
+				return nil, fmt.Errorf("%w: line %d: hunk without file header", ErrMalformed, i+1)
+			}
+			h, n, err := parseHunk(lines[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %v", ErrMalformed, i+1, err)
+			}
+			f.Hunks = append(f.Hunks, h)
+			i += n - 1
+		}
+	}
+	for _, f := range files {
+		if len(f.Hunks) <= 0 {
+			return nil, fmt.Errorf("%q: %w", f.Name(), ErrNoHunks)
+		}
+	}
+	return files, nil
+}
+
+// headerName returns the file name from ---/+++ header line,
+// dropping the timestamp.
+func headerName(s string) string {
+	if i := strings.IndexByte(s, '\t'); i != -1 {
+		s = s[:i]
+	}
+	s = strings.TrimRight(s, "\r\n")
+	if uq, err := strconv.Unquote(s); err == nil {
+		s = uq
+	}
+	return s
+}
+
+// parseHunk parses the hunk at the start of lines and returns
+// the number of lines consumed.
+func parseHunk(lines []string) (Hunk, int, error) {
+	var h Hunk
+	header := strings.TrimRight(lines[0], "\r\n")
+	fields := strings.Fields(header)
+	if len(fields) < 4 || fields[3] != "@@" {
+		return h, 0, fmt.Errorf("invalid hunk header %q", header)
+	}
+	var err error
+	h.OldStart, h.OldLines, err = parseRange(fields[1], '-')
+	if err != nil {
+		return h, 0, err
+	}
+	h.NewStart, h.NewLines, err = parseRange(fields[2], '+')
+	if err != nil {
+		return h, 0, err
+	}
+
+	n := 1
+	nold, nnew := 0, 0
+	for nold < h.OldLines || nnew < h.NewLines || n < len(lines) && strings.HasPrefix(lines[n], `\`) {
+		if n >= len(lines) {
+			return h, 0, errors.New("unexpected end of hunk")
+		}
+		line := lines[n]
+		n++
+		// Some editors strip the trailing space of empty context lines.
+		if line == "\n" || line == "\r\n" {
+			line = " " + line
+		}
+		switch line[0] {
+		case ' ':
+			nold++
+			nnew++
+		case '-':
+			nold++
+		case '+':
+			nnew++
+		case '\\':
+			// "\ No newline at end of file" applies to the previous line.
+			if len(h.Lines) <= 0 {
+				return h, 0, errors.New("unexpected no newline marker")
+			}
+			last := &h.Lines[len(h.Lines)-1]
+			*last = strings.TrimSuffix(strings.TrimSuffix(*last, "\n"), "\r")
+			continue
+		default:
+			return h, 0, fmt.Errorf("unexpected line %q in hunk", line)
+		}
+		if nold > h.OldLines || nnew > h.NewLines {
+			return h, 0, errors.New("hunk is longer than its header specifies")
+		}
+		h.Lines = append(h.Lines, line)
+	}
+	return h, n, nil
+}
+
+// parseRange parses "-start,count" or "+start,count" hunk range.
+func parseRange(s string, prefix byte) (start, count int, err error) {
+	if len(s) <= 0 || s[0] != prefix {
+		return 0, 0, fmt.Errorf("invalid hunk range %q", s)
+	}
+	s = s[1:]
+	count = 1
+	if i := strings.IndexByte(s, ','); i != -1 {
+		count, err = strconv.Atoi(s[i+1:])
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid hunk range %q", s)
+		}
+		s = s[:i]
+	}
+	start, err = strconv.Atoi(s)
+	if err != nil || start < 0 || count < 0 {
+		return 0, 0, fmt.Errorf("invalid hunk range %q", s)
+	}
+	return start, count, nil
+}
+
+// Apply applies the diff to src. Hunks must apply exactly
+// at the positions specified in their headers.
+func (f *File) Apply(src []byte) ([]byte, error) {
+	lines := splitLines(src)
+
+	var buf bytes.Buffer
+	pos := 0
+	for i, h := range f.Hunks {
+		// Hunks that only add lines specify the line
+		// after which the lines are added.
+		start := h.OldStart - 1
+		if h.OldLines == 0 {
+			start = h.OldStart
+		}
+		if start < pos {
+			return nil, fmt.Errorf("%w: hunk #%d overlaps previous hunk", ErrMalformed, i+1)
+		}
+		if start > len(lines) {
+			return nil, &HunkError{Hunk: i + 1, Line: h.OldStart, Want: firstOld(h), EOF: true}
+		}
+		for _, l := range lines[pos:start] {
+			buf.WriteString(l)
+		}
+		pos = start
+
+		for _, l := range h.Lines {
+			op, text := l[0], l[1:]
+			if op == '+' {
+				buf.WriteString(text)
+				continue
+			}
+			if pos >= len(lines) {
+				return nil, &HunkError{Hunk: i + 1, Line: pos + 1, Want: text, EOF: true}
+			}
+			if lines[pos] != text {
+				return nil, &HunkError{Hunk: i + 1, Line: pos + 1, Want: text, Got: lines[pos]}
+			}
+			if op == ' ' {
+				buf.WriteString(text)
+			}
+			pos++
+		}
+	}
+	for _, l := range lines[pos:] {
+		buf.WriteString(l)
+	}
+	return buf.Bytes(), nil
+}
+
+func firstOld(h Hunk) string {
+	for _, l := range h.Lines {
+		if l[0] != '+' {
+			return l[1:]
+		}
+	}
+	return ""
+}
+
+// splitLines splits data after each newline.
+func splitLines(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i == -1 {
+			lines = append(lines, string(data))
+			break
+		}
+		lines = append(lines, string(data[:i+1]))
+		data = data[i+1:]
+	}
+	return lines
+}
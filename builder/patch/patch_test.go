@@ -0,0 +1,262 @@
+package patch
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Src      string
+		Diff     string
+		File     string
+		Expected string
+	}{
+		{
+			Name: "Replace",
+			Src:  "a\nb\nc\n",
+			Diff: "--- a/mod.txt\n+++ b/mod.txt\n" +
+				"@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			File:     "mod.txt",
+			Expected: "a\nB\nc\n",
+		},
+		{
+			Name: "MultipleHunks",
+			Src:  "1\n2\n3\n4\n5\n6\n7\n8\n",
+			Diff: "--- a/mod.txt\n+++ b/mod.txt\n" +
+				"@@ -1,2 +1,2 @@\n-1\n+one\n 2\n" +
+				"@@ -6,3 +6,4 @@\n 6\n+6.5\n 7\n-8\n+eight\n",
+			File:     "mod.txt",
+			Expected: "one\n2\n3\n4\n5\n6\n6.5\n7\neight\n",
+		},
+		{
+			Name: "InsertOnly",
+			Src:  "a\nb\n",
+			Diff: "--- a/mod.txt\n+++ b/mod.txt\n" +
+				"@@ -1,0 +2,2 @@\n+x\n+y\n",
+			File:     "mod.txt",
+			Expected: "a\nx\ny\nb\n",
+		},
+		{
+			Name: "InsertAtStart",
+			Src:  "a\n",
+			Diff: "--- a/mod.txt\n+++ b/mod.txt\n" +
+				"@@ -0,0 +1 @@\n+x\n",
+			File:     "mod.txt",
+			Expected: "x\na\n",
+		},
+		{
+			Name: "NewFile",
+			Diff: "--- /dev/null\n+++ b/new.txt\n" +
+				"@@ -0,0 +1,2 @@\n+a\n+b\n",
+			File:     "new.txt",
+			Expected: "a\nb\n",
+		},
+		{
+			Name: "DeleteAll",
+			Src:  "a\nb\nc\n",
+			Diff: "--- a/mod.txt\n+++ /dev/null\n" +
+				"@@ -1,3 +0,0 @@\n-a\n-b\n-c\n",
+			File:     "mod.txt",
+			Expected: "",
+		},
+		{
+			Name: "NoNewlineOld",
+			Src:  "a\nb",
+			Diff: "--- a/mod.txt\n+++ b/mod.txt\n" +
+				"@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+			File:     "mod.txt",
+			Expected: "a\nb\n",
+		},
+		{
+			Name: "NoNewlineNew",
+			Src:  "a\nb\n",
+			Diff: "--- a/mod.txt\n+++ b/mod.txt\n" +
+				"@@ -1,2 +1,2 @@\n a\n-b\n+c\n\\ No newline at end of file\n",
+			File:     "mod.txt",
+			Expected: "a\nc",
+		},
+		{
+			Name: "NoNewlineBoth",
+			Src:  "a\nb",
+			Diff: "--- a/mod.txt\n+++ b/mod.txt\n" +
+				"@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+			File:     "mod.txt",
+			Expected: "a\nc",
+		},
+		{
+			Name: "GitHeaders",
+			Src:  "a\nb\n",
+			Diff: "diff --git a/config/mod.cfg b/config/mod.cfg\n" +
+				"index 0123456..789abcd 100644\n" +
+				"--- a/config/mod.cfg\n+++ b/config/mod.cfg\n" +
+				"@@ -1,2 +1,2 @@ section\n a\n-b\n+c\n",
+			File:     "config/mod.cfg",
+			Expected: "a\nc\n",
+		},
+		{
+			Name: "TimestampHeaders",
+			Src:  "a\n",
+			Diff: "--- mod.txt\t2020-01-01 00:00:00.000000000 +0000\n" +
+				"+++ mod.txt\t2020-01-02 00:00:00.000000000 +0000\n" +
+				"@@ -1 +1 @@\n-a\n+b\n",
+			File:     "mod.txt",
+			Expected: "b\n",
+		},
+		{
+			Name: "CRLF",
+			Src:  "a\r\nb\r\nc\r\n",
+			Diff: "--- a/mod.txt\r\n+++ b/mod.txt\r\n" +
+				"@@ -1,3 +1,3 @@\r\n a\r\n-b\r\n+B\r\n c\r\n",
+			File:     "mod.txt",
+			Expected: "a\r\nB\r\nc\r\n",
+		},
+		{
+			Name: "StrippedContext",
+			Src:  "a\n\nb\n",
+			Diff: "--- a/mod.txt\n+++ b/mod.txt\n" +
+				"@@ -1,3 +1,3 @@\n a\n\n-b\n+c\n",
+			File:     "mod.txt",
+			Expected: "a\n\nc\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			files, err := Parse([]byte(tt.Diff))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != 1 {
+				t.Fatalf("got %d files, expected 1", len(files))
+			}
+			f := files[0]
+			if name := f.Name(); name != tt.File {
+				t.Errorf("got name %q, expected %q", name, tt.File)
+			}
+			out, err := f.Apply([]byte(tt.Src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tt.Expected {
+				t.Fatalf("got %q, expected %q", out, tt.Expected)
+			}
+		})
+	}
+}
+
+func TestApplyMismatch(t *testing.T) {
+	tests := []struct {
+		Name string
+		Src  string
+		Diff string
+		Err  HunkError
+	}{
+		{
+			Name: "Context",
+			Src:  "a\nx\nc\n",
+			Diff: "--- a/mod.txt\n+++ b/mod.txt\n" +
+				"@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			Err: HunkError{Hunk: 1, Line: 2, Want: "b\n", Got: "x\n"},
+		},
+		{
+			Name: "SecondHunk",
+			Src:  "1\n2\n3\n4\n5\n",
+			Diff: "--- a/mod.txt\n+++ b/mod.txt\n" +
+				"@@ -1 +1 @@\n-1\n+one\n" +
+				"@@ -4,2 +4,2 @@\n 4\n-6\n+six\n",
+			Err: HunkError{Hunk: 2, Line: 5, Want: "6\n", Got: "5\n"},
+		},
+		{
+			Name: "MissingNewline",
+			Src:  "a\nb",
+			Diff: "--- a/mod.txt\n+++ b/mod.txt\n" +
+				"@@ -1,2 +1,2 @@\n a\n-b\n+c\n",
+			Err: HunkError{Hunk: 1, Line: 2, Want: "b\n", Got: "b"},
+		},
+		{
+			Name: "CRLF",
+			Src:  "a\r\nb\r\n",
+			Diff: "--- a/mod.txt\n+++ b/mod.txt\n" +
+				"@@ -1,2 +1,2 @@\n a\n-b\n+c\n",
+			Err: HunkError{Hunk: 1, Line: 1, Want: "a\n", Got: "a\r\n"},
+		},
+		{
+			Name: "EOF",
+			Src:  "a\n",
+			Diff: "--- a/mod.txt\n+++ b/mod.txt\n" +
+				"@@ -1,2 +1,2 @@\n a\n-b\n+c\n",
+			Err: HunkError{Hunk: 1, Line: 2, Want: "b\n", EOF: true},
+		},
+		{
+			Name: "PastEOF",
+			Src:  "a\n",
+			Diff: "--- a/mod.txt\n+++ b/mod.txt\n" +
+				"@@ -5,1 +5,1 @@\n-e\n+f\n",
+			Err: HunkError{Hunk: 1, Line: 5, Want: "e\n", EOF: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			files, err := Parse([]byte(tt.Diff))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = files[0].Apply([]byte(tt.Src))
+			var he *HunkError
+			if !errors.As(err, &he) {
+				t.Fatalf("got %v, expected hunk error", err)
+			}
+			if *he != tt.Err {
+				t.Fatalf("got %+v, expected %+v", *he, tt.Err)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		Name string
+		Diff string
+		Err  error
+	}{
+		{
+			Name: "NoHunks",
+			Diff: "--- a/mod.txt\n+++ b/mod.txt\n",
+			Err:  ErrNoHunks,
+		},
+		{
+			Name: "NoFileHeader",
+			Diff: "@@ -1 +1 @@\n-a\n+b\n",
+			Err:  ErrMalformed,
+		},
+		{
+			Name: "InvalidRange",
+			Diff: "--- a/mod.txt\n+++ b/mod.txt\n@@ -x +1 @@\n-a\n+b\n",
+			Err:  ErrMalformed,
+		},
+		{
+			Name: "ShortHunk",
+			Diff: "--- a/mod.txt\n+++ b/mod.txt\n@@ -1,2 +1,2 @@\n a\n-b\n",
+			Err:  ErrMalformed,
+		},
+		{
+			Name: "LongHunk",
+			Diff: "--- a/mod.txt\n+++ b/mod.txt\n@@ -1 +1,2 @@\n a\n b\n",
+			Err:  ErrMalformed,
+		},
+		{
+			Name: "UnexpectedLine",
+			Diff: "--- a/mod.txt\n+++ b/mod.txt\n@@ -1,2 +1,2 @@\n a\n*b\n",
+			Err:  ErrMalformed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.Diff))
+			if !errors.Is(err, tt.Err) {
+				t.Fatalf("got %v, expected %v", err, tt.Err)
+			}
+		})
+	}
+}
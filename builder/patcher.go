@@ -0,0 +1,105 @@
+package builder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/tie/modpacker/builder/patch"
+)
+
+var ErrPatchUnused = errors.New("patch does not match any file")
+
+// Patcher applies file diffs from local patch files.
+// A nil Patcher applies no diffs.
+type Patcher struct {
+	files   []*patch.File
+	sources []string
+	applied []bool
+}
+
+// ReadPatches reads and parses patch files.
+func ReadPatches(paths []string) (*Patcher, error) {
+	if len(paths) <= 0 {
+		return nil, nil
+	}
+	p := &Patcher{}
+	for _, fpath := range paths {
+		data, err := ioutil.ReadFile(filepath.FromSlash(fpath))
+		if err != nil {
+			return nil, err
+		}
+		files, err := patch.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("patch %q: %w", fpath, err)
+		}
+		for _, f := range files {
+			p.files = append(p.files, f)
+			p.sources = append(p.sources, fpath)
+		}
+	}
+	p.applied = make([]bool, len(p.files))
+	return p, nil
+}
+
+// Patch applies diffs for the file name to the data read from r.
+func (p *Patcher) Patch(r io.Reader, name string) (io.Reader, error) {
+	return p.patch(r, func(f *patch.File) bool {
+		return f.Name() == name
+	})
+}
+
+// PatchAll applies all diffs to the data read from r,
+// regardless of the file names.
+func (p *Patcher) PatchAll(r io.Reader) (io.Reader, error) {
+	return p.patch(r, func(*patch.File) bool {
+		return true
+	})
+}
+
+func (p *Patcher) patch(r io.Reader, match func(*patch.File) bool) (io.Reader, error) {
+	if p == nil {
+		return r, nil
+	}
+	var data []byte
+	read := false
+	for i, f := range p.files {
+		if !match(f) {
+			continue
+		}
+		if !read {
+			b, err := ioutil.ReadAll(r)
+			if err != nil {
+				return nil, err
+			}
+			data, read = b, true
+		}
+		b, err := f.Apply(data)
+		if err != nil {
+			return nil, fmt.Errorf("patch %q: %q: %w", p.sources[i], f.Name(), err)
+		}
+		data = b
+		p.applied[i] = true
+	}
+	if !read {
+		return r, nil
+	}
+	return bytes.NewReader(data), nil
+}
+
+// Check returns an error if some of the diffs were not applied.
+func (p *Patcher) Check() error {
+	if p == nil {
+		return nil
+	}
+	for i, ok := range p.applied {
+		if ok {
+			continue
+		}
+		return fmt.Errorf("patch %q: %q: %w", p.sources[i], p.files[i].Name(), ErrPatchUnused)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package builder
+
+import (
+	"io"
+	"io/ioutil"
+	"text/template"
+
+	"github.com/tie/modpacker/modpacker"
+)
+
+// TemplateData is the data available to files rendered
+// with "template" action.
+type TemplateData struct {
+	// Var and Local contain values of input variables
+	// and local values from manifests.
+	Var   map[string]interface{}
+	Local map[string]interface{}
+	// Modpack is the modpack metadata.
+	Modpack modpacker.Modpack
+}
+
+// Render executes the template read from r with data d and writes
+// the result to w. References to missing map keys are errors.
+func (d TemplateData) Render(w io.Writer, r io.Reader, name string) error {
+	text, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	t, err := template.New(name).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return err
+	}
+	return t.Execute(w, d)
+}
@@ -5,18 +5,15 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 
 	"github.com/google/subcommands"
 
-	"github.com/hashicorp/hcl/v2/gohcl"
-	"github.com/hashicorp/hcl/v2/hclwrite"
-
-	"github.com/tie/internal/renameio"
-
 	"github.com/tie/modpacker/builder/curse/jsonspec"
+	"github.com/tie/modpacker/modpacker"
 	"github.com/tie/modpacker/pack/hclspec"
 )
 
@@ -56,6 +53,18 @@ func (cmd *BootstrapCommand) Execute(ctx context.Context, fs *flag.FlagSet, args
 	}
 
 	var m hclspec.Manifest
+	m.Modpack = &hclspec.Modpack{
+		Name:        cm.Name,
+		Version:     cm.Version,
+		Author:      cm.Author,
+		Description: cm.Desc,
+		Minecraft:   cm.Minecraft.Version,
+	}
+	for _, l := range cm.Minecraft.ModLoaders {
+		if l.Primary {
+			m.Modpack.Loader = l.ID
+		}
+	}
 	m.Mods = make([]hclspec.Mod, len(cm.Files))
 	for i, cf := range cm.Files {
 		path := fmt.Sprintf("mods/%d-%d.jar", cf.ProjectID, cf.FileID)
@@ -67,36 +76,27 @@ func (cmd *BootstrapCommand) Execute(ctx context.Context, fs *flag.FlagSet, args
 		}
 	}
 
+	// Add top-level directories of overrides as a whole,
+	// so that the manifest does not list every single file.
 	opath := filepath.FromSlash(cm.Overrides)
-	err = filepath.Walk(cm.Overrides, func(fpath string, fi os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if fi.IsDir() {
-			return nil
-		}
-		rpath, err := filepath.Rel(opath, fpath)
-		if err != nil {
-			return err
-		}
+	fis, err := ioutil.ReadDir(opath)
+	if err != nil {
+		log.Printf("read dir %q: %+v", opath, err)
+		return subcommands.ExitFailure
+	}
+	for _, fi := range fis {
+		fpath := filepath.ToSlash(filepath.Join(opath, fi.Name()))
 		mod := hclspec.Mod{
-			Path: rpath,
+			Path: fi.Name(),
 			File: fpath,
 		}
+		if fi.IsDir() {
+			mod.Method = modpacker.MethodDir
+		}
 		m.Mods = append(m.Mods, mod)
-		return nil
-	})
-	if err != nil {
-		log.Printf("walk %q: %+v", cm.Overrides, err)
-		return subcommands.ExitFailure
 	}
 
-	conf := hclwrite.NewEmptyFile()
-	body := conf.Body()
-	gohcl.EncodeIntoBody(&m, body)
-	data := conf.Bytes()
-
-	err = renameio.WriteFile(cmd.OutputPath, data, 0644)
+	err = writeManifest(cmd.OutputPath, &m)
 	if err != nil {
 		log.Printf("write %q: %+v", cmd.OutputPath, err)
 		return subcommands.ExitFailure
@@ -6,19 +6,14 @@ import (
 	"context"
 	"flag"
 	"log"
-	"net/http"
 	"os"
 
 	"github.com/google/subcommands"
 
-	"github.com/go-git/go-billy/v5"
-	"github.com/go-git/go-billy/v5/memfs"
-	"github.com/go-git/go-billy/v5/osfs"
-
 	"github.com/tie/modpacker/builder"
 	"github.com/tie/modpacker/builder/archive"
 	"github.com/tie/modpacker/builder/curse"
-	"github.com/tie/modpacker/fetcher"
+	"github.com/tie/modpacker/modpacker"
 	"github.com/tie/modpacker/pack"
 )
 
@@ -30,19 +25,46 @@ const (
 type CompileCommand struct {
 	OutputMode   string
 	OutputPath   string
+	Side         string
+	With         stringsFlag
+	Without      stringsFlag
 	DisableCache bool
+	Jobs         int
+	Fetch        FetchFlags
+	Vars         VarFlags
 }
 
 func (*CompileCommand) Name() string     { return "compile" }
 func (*CompileCommand) Synopsis() string { return "compile the modpack" }
 func (*CompileCommand) Usage() string {
-	return `Usage: modpacker compile [-o modpack.zip] [-mode standalone] [-nocache] [manifest paths]
+	return `Usage: modpacker compile [-o modpack.zip] [-mode standalone] [-side both] [-with group] [-without group] [-j n] [-timeout duration] [-retries n] [-nocache] [-var name=value] [-var-file file] [manifest paths]
 
 	Compiles the modpack from manifests. The output is a zip archive
 	containing files specified by "mod" blocks. For each corresponding
 	"check" block the integrity of the mods is verified. Use "sums"
 	subcommand to generate sums manifest for an existing set of files.
 
+	Manifests may include other manifests using "import" blocks. Import
+	paths are relative to the importing manifest, and imported manifests
+	are loaded before the manifest that imports them.
+
+	Manifests are applied in order. A "mod" block replaces the mod with
+	the same path from previous manifests, and a "remove" block drops it.
+
+	Manifests may declare input variables using "variable" blocks and
+	local values using "locals" blocks, available in expressions as
+	var.<name> and local.<name>. Values of the variables are set with
+	-var and -var-file options.
+
+	Files with "template" action are rendered using Go text/template
+	package. Templates can refer to input variables as {{.Var.name}},
+	local values as {{.Local.name}} and "modpack" block fields as
+	{{.Modpack.Name}}.
+
+	Unified diffs listed in "patches" attribute are applied to the file
+	with "patch" action, or to the files extracted from archive with
+	matching names relative to the mod path. Hunks must apply exactly.
+
         The layout of the files in output archive is specified by -mode
         option. The supported modes are:
 
@@ -53,7 +75,18 @@ func (*CompileCommand) Usage() string {
                 Archive compatible with CurseForge/Twitch launcher.
                 In this mode "mod" blocks with "curse" method will be added
                 to manifest.json file instead of being downloaded. The sums
-                for those blocks are therefore ignored.
+                for those blocks are therefore ignored. The name, version,
+                minecraft and loader fields of "modpack" block are required.
+
+	The -side option selects mods for the client or the server. Mods
+	with "side" attribute set to the other side are skipped. By default,
+	mods are required on both sides.
+
+	Mods can be assigned to groups using "groups" attribute. The -without
+	option excludes mods from the group, and -with option includes optional
+	mods from the group. Both options can be repeated. Mods with "optional"
+	attribute set are otherwise skipped, except for "curse" mods in curse
+	mode that are added to manifest.json as optional files.
 
 Flags:
 `
@@ -61,8 +94,14 @@ Flags:
 
 func (cmd *CompileCommand) SetFlags(fs *flag.FlagSet) {
 	fs.BoolVar(&cmd.DisableCache, "nocache", false, "disable filesystem cache")
+	fs.IntVar(&cmd.Jobs, "j", defaultJobs, "download up to `n` mods concurrently")
+	cmd.Fetch.SetFlags(fs)
 	fs.StringVar(&cmd.OutputPath, "o", "modpack.zip", "modpack output path")
 	fs.StringVar(&cmd.OutputMode, "mode", OutputModeStandalone, "modpack output mode")
+	fs.StringVar(&cmd.Side, "side", modpacker.SideBoth, "include only mods for `side` (client, server or both)")
+	fs.Var(&cmd.With, "with", "include optional mods from `group`")
+	fs.Var(&cmd.Without, "without", "exclude mods from `group`")
+	cmd.Vars.SetFlags(fs)
 }
 
 func (cmd *CompileCommand) Execute(ctx context.Context, fs *flag.FlagSet, args ...interface{}) (rc subcommands.ExitStatus) {
@@ -79,22 +118,34 @@ func (cmd *CompileCommand) Execute(ctx context.Context, fs *flag.FlagSet, args .
 		return subcommands.ExitFailure
 	}
 
-	ms, ok := parseManifests(paths)
+	switch cmd.Side {
+	case modpacker.SideBoth:
+	case modpacker.SideClient:
+	case modpacker.SideServer:
+	default:
+		log.Printf("unknown side: %q", cmd.Side)
+		return subcommands.ExitFailure
+	}
+
+	var checks []manifestCheck
+	if cmd.OutputMode == OutputModeCurse {
+		checks = append(checks, pack.CheckCurseModpack)
+	}
+
+	ms, mods, ok := loadManifests(paths, &cmd.Vars, checks...)
 	if !ok {
 		return subcommands.ExitFailure
 	}
+	if !checkGroups(mods, cmd.With) || !checkGroups(mods, cmd.Without) {
+		return subcommands.ExitFailure
+	}
 
-	var cacheDir billy.Filesystem
-	if !cmd.DisableCache {
-		cache, err := makeCache(programName)
-		if err != nil {
-			log.Printf("make cache: %+v", err)
-			return subcommands.ExitFailure
-		}
-		cacheDir = osfs.New(cache)
-	} else {
-		cacheDir = memfs.New()
+	cacheDir, cleanup, err := openCache(cmd.DisableCache)
+	if err != nil {
+		log.Printf("make cache: %+v", err)
+		return subcommands.ExitFailure
 	}
+	defer cleanup()
 
 	fpath := cmd.OutputPath
 	f, err := os.Create(fpath)
@@ -120,26 +171,88 @@ func (cmd *CompileCommand) Execute(ctx context.Context, fs *flag.FlagSet, args .
 		}
 	}()
 
-	fetcher := &fetcher.Fetcher{
-		Files:  cacheDir,
-		Client: &http.Client{},
+	fetcher := cmd.Fetch.newFetcher(cacheDir)
+
+	vars, locals := pack.Values(ms)
+	data := builder.TemplateData{
+		Var:     vars,
+		Local:   locals,
+		Modpack: pack.Modpack(ms),
 	}
 
 	var b builder.Builder
 	switch cmd.OutputMode {
 	case OutputModeStandalone:
-		b = archive.NewArchiveBuilder(fetcher, z)
+		ab := archive.NewArchiveBuilder(fetcher, z)
+		ab.Template = data
+		b = ab
 	case OutputModeCurse:
-		b = curse.NewCurseBuilder(fetcher, z)
+		cb := curse.NewCurseBuilder(fetcher, z, data.Modpack)
+		cb.Template = data
+		b = cb
+	}
+
+	var selected []modpacker.Mod
+	for _, mod := range mods {
+		if !mod.OnSide(cmd.Side) || mod.InGroups(cmd.Without) {
+			continue
+		}
+		if mod.InGroups(cmd.With) {
+			mod.Optional = false
+		}
+		selected = append(selected, mod)
+	}
+
+	// Download mods concurrently before adding them
+	// to the archive in order.
+	var fetch []modpacker.Mod
+	for _, mod := range selected {
+		if mod.Optional {
+			continue
+		}
+		if cmd.OutputMode == OutputModeCurse && mod.Method == modpacker.MethodCurse && mod.Action == modpacker.ActionNone {
+			continue
+		}
+		fetch = append(fetch, mod)
+	}
+	i, err := forEach(cmd.Jobs, len(fetch), func(i int) error {
+		return fetcher.Cache(ctx, fetch[i])
+	})
+	if err != nil {
+		log.Printf("download %q mod: %+v", fetch[i].Method, err)
+		return subcommands.ExitFailure
 	}
 
-	for _, mod := range pack.ModList(ms) {
-		err := b.Add(mod)
+	for _, mod := range selected {
+		err := b.Add(ctx, mod)
 		if err != nil {
 			log.Printf("add %q mod: %+v", mod.Method, err)
 			return subcommands.ExitFailure
 		}
 	}
+	if err := b.Close(); err != nil {
+		log.Printf("close builder: %+v", err)
+		return subcommands.ExitFailure
+	}
 
 	return subcommands.ExitSuccess
 }
+
+// checkGroups reports whether all groups are used by mods.
+func checkGroups(mods []modpacker.Mod, groups []string) bool {
+	ok := true
+	for _, g := range groups {
+		found := false
+		for i := range mods {
+			if mods[i].InGroups([]string{g}) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			log.Printf("unknown group: %q", g)
+			ok = false
+		}
+	}
+	return ok
+}
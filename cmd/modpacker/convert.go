@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/subcommands"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/tie/internal/renameio"
+	"github.com/tie/internal/robustio"
+
+	"github.com/tie/modpacker/pack/hclspec"
+)
+
+type ConvertCommand struct {
+	OutputPath string
+	Vars       VarFlags
+}
+
+func (*ConvertCommand) Name() string     { return "convert" }
+func (*ConvertCommand) Synopsis() string { return "convert manifest syntax" }
+func (*ConvertCommand) Usage() string {
+	return `Usage: modpacker convert [-o path] [-var name=value] [-var-file file] manifest
+
+	Converts manifest between native and JSON syntax. Manifests with
+	.json extension use JSON syntax. By default, the output path is the
+	manifest path with .json extension added or removed.
+
+	The output is the decoded manifest, that is, expressions are evaluated
+	and variable and locals blocks are omitted. Import blocks are kept
+	as is.
+
+Flags:
+`
+}
+
+func (cmd *ConvertCommand) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.OutputPath, "o", "", "manifest output path")
+	cmd.Vars.SetFlags(fs)
+}
+
+func (cmd *ConvertCommand) Execute(ctx context.Context, fs *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if fs.NArg() != 1 {
+		return subcommands.ExitUsageError
+	}
+	fpath := fs.Arg(0)
+
+	opath := cmd.OutputPath
+	if opath == "" {
+		if isJSON(fpath) {
+			opath = strings.TrimSuffix(fpath, filepath.Ext(fpath))
+		} else {
+			opath = fpath + ".json"
+		}
+	}
+
+	m, ok := cmd.decode(fpath)
+	if !ok {
+		return subcommands.ExitFailure
+	}
+	if err := writeManifest(opath, &m); err != nil {
+		log.Printf("write %q: %+v", opath, err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+func (cmd *ConvertCommand) decode(fpath string) (hclspec.Manifest, bool) {
+	var m hclspec.Manifest
+
+	parser := hclparse.NewParser()
+	diagWr, _ := newDiagWr(parser)
+
+	src, err := robustio.ReadFile(fpath)
+	if err != nil {
+		log.Printf("read %q: %+v", fpath, err)
+		return m, false
+	}
+
+	vs, diags := cmd.Vars.values(parser)
+	if !diags.HasErrors() {
+		vars := make(map[string]cty.Value, len(vs))
+		for name, v := range vs {
+			vars[name] = v.Value
+		}
+		file, parseDiags := parseFile(parser, src, fpath)
+		diags = append(diags, parseDiags...)
+		if !parseDiags.HasErrors() {
+			var decodeDiags hcl.Diagnostics
+			m, decodeDiags = hclspec.DecodeManifest(file.Body, vars)
+			diags = append(diags, decodeDiags...)
+		}
+	}
+
+	if err := diagWr.WriteDiagnostics(diags); err != nil {
+		log.Printf("write diags: %+v", err)
+		return m, false
+	}
+	return m, !diags.HasErrors()
+}
+
+// writeManifest writes manifest using either native or JSON syntax
+// depending on the file extension.
+func writeManifest(fpath string, m *hclspec.Manifest) error {
+	var data []byte
+	if isJSON(fpath) {
+		var err error
+		data, err = hclspec.EncodeJSON(m)
+		if err != nil {
+			return err
+		}
+	} else {
+		data = hclspec.EncodeHCL(m)
+	}
+	return renameio.WriteFile(fpath, data, 0644)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+
+	"github.com/tie/modpacker/fetcher"
+)
+
+// defaultJobs is the default number of mods fetched concurrently.
+const defaultJobs = 4
+
+// FetchFlags configure HTTP requests of the fetcher.
+type FetchFlags struct {
+	Timeout     time.Duration
+	Retries     int
+	ModrinthURL string
+	GitHubURL   string
+}
+
+func (f *FetchFlags) SetFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&f.Timeout, "timeout", 10*time.Minute, "limit each HTTP request to `duration`")
+	fs.IntVar(&f.Retries, "retries", 3, "retry HTTP requests failed with transient errors `n` times")
+	fs.StringVar(&f.ModrinthURL, "modrinth-api", fetcher.DefaultModrinthURL, "Modrinth API base `url`")
+	fs.StringVar(&f.GitHubURL, "github-api", fetcher.DefaultGitHubURL, "GitHub API base `url`, authenticated with GITHUB_TOKEN environment variable if set")
+}
+
+// newFetcher returns the fetcher that uses files as the cache.
+// GitHub API requests are authenticated with the token from
+// GITHUB_TOKEN environment variable, if set.
+func (f *FetchFlags) newFetcher(files billy.Filesystem) *fetcher.Fetcher {
+	return &fetcher.Fetcher{
+		Files:       files,
+		Client:      &http.Client{},
+		Timeout:     f.Timeout,
+		Retries:     f.Retries,
+		ModrinthURL: f.ModrinthURL,
+		GitHubURL:   f.GitHubURL,
+		GitHubToken: os.Getenv("GITHUB_TOKEN"),
+	}
+}
+
+// openCache returns the cache filesystem. If disable is set, the cache
+// is a temporary directory that is removed by the cleanup function.
+//
+// Unlike memfs, the temporary directory is safe for concurrent use.
+func openCache(disable bool) (fs billy.Filesystem, cleanup func(), err error) {
+	if !disable {
+		cache, err := makeCache(programName)
+		if err != nil {
+			return nil, nil, err
+		}
+		return osfs.New(cache), func() {}, nil
+	}
+	tmp, err := ioutil.TempDir("", programName)
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup = func() {
+		if err := os.RemoveAll(tmp); err != nil {
+			log.Printf("remove %q: %+v", tmp, err)
+		}
+	}
+	return osfs.New(tmp), cleanup, nil
+}
+
+// forEach calls fn for indices from 0 to n-1 using at most jobs
+// goroutines. Once fn returns an error, no new calls are started.
+// It returns the smallest index for which fn failed and the error.
+func forEach(jobs, n int, fn func(i int) error) (int, error) {
+	if jobs < 1 {
+		jobs = 1
+	}
+	errs := make([]error, n)
+	var failed int32
+
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for j := 0; j < jobs; j++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				if err := fn(i); err != nil {
+					errs[i] = err
+					atomic.StoreInt32(&failed, 1)
+				}
+			}
+		}()
+	}
+	for i := 0; i < n && atomic.LoadInt32(&failed) == 0; i++ {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return i, err
+		}
+	}
+	return -1, nil
+}
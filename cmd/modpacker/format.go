@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
@@ -15,13 +16,13 @@ import (
 	"github.com/google/subcommands"
 
 	"github.com/hashicorp/hcl/v2"
-	"github.com/hashicorp/hcl/v2/gohcl"
 	"github.com/hashicorp/hcl/v2/hclparse"
 	"github.com/hashicorp/hcl/v2/hclwrite"
 
 	"github.com/tie/internal/renameio"
 	"github.com/tie/internal/robustio"
 
+	"github.com/tie/modpacker/pack"
 	"github.com/tie/modpacker/pack/hclspec"
 )
 
@@ -38,6 +39,12 @@ func (*FormatCommand) Usage() string {
 
 	Formats manifests using standard syntax. It can either write files
 	in-places or generate unified diff with specified context size.
+	Manifests with .json extension use JSON syntax and are indented
+	with two spaces.
+	Unless -nocheck is set, manifests are checked against the manifest
+	schema and diagnostics are reported, including mods with unknown
+	methods. Expressions are not evaluated, so input variables are not
+	needed to format manifests.
 
 Flags:
 `
@@ -78,7 +85,7 @@ func (cmd *FormatCommand) Execute(ctx context.Context, fs *flag.FlagSet, args ..
 		}
 
 		if !cmd.DisableCheck {
-			file, diags := parser.ParseHCL(src, fpath)
+			file, diags := parseFile(parser, src, fpath)
 			if diags.HasErrors() {
 				err := diagWr.WriteDiagnostics(diags)
 				if err != nil {
@@ -86,9 +93,9 @@ func (cmd *FormatCommand) Execute(ctx context.Context, fs *flag.FlagSet, args ..
 				}
 				return subcommands.ExitFailure
 			}
-			var m hclspec.Manifest
-			decodeDiags := gohcl.DecodeBody(file.Body, nil, &m)
-			diags = append(diags, decodeDiags...)
+			mods, schemaDiags := hclspec.CheckSchema(file.Body)
+			diags = append(diags, schemaDiags...)
+			diags = append(diags, pack.CheckMethods(mods)...)
 			err := diagWr.WriteDiagnostics(diags)
 			if err != nil {
 				log.Printf("write diags: %+v", err)
@@ -99,7 +106,11 @@ func (cmd *FormatCommand) Execute(ctx context.Context, fs *flag.FlagSet, args ..
 			}
 		}
 
-		outSrc := hclwrite.Format(src)
+		outSrc, err := formatSource(src, fpath)
+		if err != nil {
+			log.Printf("format %q: %+v", fpath, err)
+			return subcommands.ExitFailure
+		}
 		if bytes.Equal(src, outSrc) {
 			continue
 		}
@@ -135,6 +146,18 @@ func (cmd *FormatCommand) Execute(ctx context.Context, fs *flag.FlagSet, args ..
 	return subcommands.ExitSuccess
 }
 
+func formatSource(src []byte, fpath string) ([]byte, error) {
+	if !isJSON(fpath) {
+		return hclwrite.Format(src), nil
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, bytes.TrimSpace(src), "", "  "); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
 func splitLines(b []byte) [][]byte {
 	return bytes.Split(b, []byte("\n"))
 }
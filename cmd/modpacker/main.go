@@ -5,6 +5,7 @@ import (
 	"flag"
 	"log"
 	"os"
+	"os/signal"
 
 	"github.com/google/subcommands"
 )
@@ -27,10 +28,12 @@ func main() {
 	cdr.Register(&BootstrapCommand{}, "")
 	cdr.Register(&CleanCommand{}, "")
 	cdr.Register(&CompileCommand{}, "")
+	cdr.Register(&ConvertCommand{}, "")
 	cdr.Register(&DownloadCommand{}, "")
 	cdr.Register(&FormatCommand{}, "")
 	cdr.Register(&ModlistCommand{}, "")
 	cdr.Register(&SumsCommand{}, "")
+	cdr.Register(&VetCommand{}, "")
 	cdr.Register(cdr.HelpCommand(), "help")
 	cdr.Register(cdr.FlagsCommand(), "help")
 	cdr.Register(cdr.CommandsCommand(), "help")
@@ -39,7 +42,18 @@ func main() {
 		log.Fatal(err)
 	}
 
-	ctx := context.Background()
+	// Cancel the context on interrupt, so that partially downloaded
+	// files are cleaned up. Second interrupt terminates the program.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		signal.Stop(sig)
+		cancel()
+	}()
+
 	switch cdr.Execute(ctx) {
 	case subcommands.ExitFailure:
 		os.Exit(1)
@@ -1,18 +1,22 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"golang.org/x/crypto/ssh/terminal"
 
 	"github.com/hashicorp/hcl/v2"
-	"github.com/hashicorp/hcl/v2/gohcl"
 	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
 
 	"github.com/tie/internal/robustio"
 
+	"github.com/tie/modpacker/modpacker"
+	"github.com/tie/modpacker/pack"
 	"github.com/tie/modpacker/pack/hclspec"
 )
 
@@ -67,52 +71,182 @@ func fdinfo(fd int) (istty, color bool) {
 	return
 }
 
-func parseManifests(paths []string) ([]hclspec.Manifest, bool) {
-	ms := make([]hclspec.Manifest, len(paths))
+// manifestCheck is an additional check for parsed manifests.
+type manifestCheck func([]hclspec.Manifest) hcl.Diagnostics
+
+// loadManifests parses manifests and merges their mods.
+func loadManifests(paths []string, vf *VarFlags, checks ...manifestCheck) ([]hclspec.Manifest, []modpacker.Mod, bool) {
+	parser := hclparse.NewParser()
+	diagWr, _ := newDiagWr(parser)
+
+	ms, diags, ok := parseManifests(parser, paths, vf)
+	var mods []modpacker.Mod
+	if ok {
+		var modDiags hcl.Diagnostics
+		mods, modDiags = pack.ModList(ms)
+		for _, check := range checks {
+			modDiags = append(modDiags, check(ms)...)
+		}
+		diags = append(diags, modDiags...)
+		ok = !modDiags.HasErrors()
+	}
+
+	if err := diagWr.WriteDiagnostics(diags); err != nil {
+		log.Printf("write diags: %+v", err)
+		return ms, mods, false
+	}
+	return ms, mods, ok
+}
+
+func parseManifests(parser *hclparse.Parser, paths []string, vf *VarFlags) ([]hclspec.Manifest, hcl.Diagnostics, bool) {
+	vars, diags := vf.values(parser)
+	if diags.HasErrors() {
+		return nil, diags, false
+	}
+
+	l := manifestLoader{
+		parser: parser,
+		vars:   make(map[string]cty.Value, len(vars)),
+		state:  make(map[string]loadState, len(paths)),
+		diags:  diags,
+	}
+	for name, v := range vars {
+		l.vars[name] = v.Value
+	}
 
 	// Continue on error to print diagnostics for all files.
 	allOK := true
-	for i, path := range paths {
-		m, ok := parseManifest(path)
-		if !ok {
+	for _, path := range paths {
+		if !l.load(path, nil) {
 			allOK = false
-			continue
 		}
-		ms[i] = m
 	}
-	return ms, allOK
+	if !allOK {
+		return l.manifests, l.diags, false
+	}
+
+	diags = checkVariables(vars, l.manifests)
+	l.diags = append(l.diags, diags...)
+	return l.manifests, l.diags, !diags.HasErrors()
 }
 
-func parseManifest(path string) (hclspec.Manifest, bool) {
-	var m hclspec.Manifest
-	var diags hcl.Diagnostics
+type loadState int
 
-	parser := hclparse.NewParser()
-	diagWr, _ := newDiagWr(parser)
+const (
+	loadNone loadState = iota
+	loadActive
+	loadDone
+)
 
-	src, err := robustio.ReadFile(path)
+// manifestLoader loads manifests following the import graph. Imported
+// manifests precede the manifest that imports them, and each manifest
+// is loaded at most once.
+//
+// Manifests are identified by absolute paths, so that the same file
+// imported with relative and absolute paths is the same manifest.
+// Diagnostics use the paths as they were given.
+type manifestLoader struct {
+	parser    *hclparse.Parser
+	vars      map[string]cty.Value
+	state     map[string]loadState
+	stack     []loadEntry
+	diags     hcl.Diagnostics
+	manifests []hclspec.Manifest
+}
+
+// loadEntry is the manifest being loaded.
+type loadEntry struct {
+	Key  string
+	Path string
+}
+
+func (l *manifestLoader) load(path string, subject *hcl.Range) bool {
+	path = filepath.Clean(path)
+	key, err := filepath.Abs(path)
 	if err != nil {
-		log.Printf("read %q: %+v", path, err)
-		return m, false
+		key = path
+	}
+	switch l.state[key] {
+	case loadDone:
+		return true
+	case loadActive:
+		var cycle []string
+		for i, e := range l.stack {
+			if e.Key != key {
+				continue
+			}
+			for _, e := range l.stack[i:] {
+				cycle = append(cycle, e.Path)
+			}
+			break
+		}
+		cycle = append(cycle, path)
+		l.diags = append(l.diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Import cycle",
+			Detail:   fmt.Sprintf("Manifest %q imports itself: %s.", path, strings.Join(cycle, " -> ")),
+			Subject:  subject,
+		})
+		return false
 	}
 
-	file, parseDiags := parser.ParseHCL(src, path)
-	diags = append(diags, parseDiags...)
-	if diags.HasErrors() {
-		// Write diagnostics on parser error.
-		err := diagWr.WriteDiagnostics(diags)
-		if err != nil {
-			log.Printf("write diags: %+v", err)
+	l.state[key] = loadActive
+	l.stack = append(l.stack, loadEntry{key, path})
+	defer func() {
+		l.stack = l.stack[:len(l.stack)-1]
+		l.state[key] = loadDone
+	}()
+
+	m, ok := l.parse(path)
+	if !ok {
+		return false
+	}
+	dir := filepath.Dir(path)
+	for _, imp := range m.Imports {
+		ipath := filepath.FromSlash(imp.Path)
+		if !filepath.IsAbs(ipath) {
+			ipath = filepath.Join(dir, ipath)
+		}
+		if !l.load(ipath, imp.DeclRange.Ptr()) {
+			ok = false
 		}
+	}
+	if ok {
+		l.manifests = append(l.manifests, m)
+	}
+	return ok
+}
+
+func (l *manifestLoader) parse(path string) (hclspec.Manifest, bool) {
+	var m hclspec.Manifest
+
+	src, err := robustio.ReadFile(path)
+	if err != nil {
+		log.Printf("read %q: %+v", path, err)
 		return m, false
 	}
 
-	decodeDiags := gohcl.DecodeBody(file.Body, nil, &m)
-	diags = append(diags, decodeDiags...)
-	if err := diagWr.WriteDiagnostics(diags); err != nil {
-		log.Printf("write diags: %+v", err)
+	file, diags := parseFile(l.parser, src, path)
+	l.diags = append(l.diags, diags...)
+	if diags.HasErrors() {
 		return m, false
 	}
 
+	m, diags = hclspec.DecodeManifest(file.Body, l.vars)
+	l.diags = append(l.diags, diags...)
 	return m, !diags.HasErrors()
 }
+
+// isJSON reports whether the file at path uses JSON syntax.
+func isJSON(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
+
+// parseFile parses src using either native or JSON syntax
+// depending on the file extension.
+func parseFile(parser *hclparse.Parser, src []byte, path string) (*hcl.File, hcl.Diagnostics) {
+	if isJSON(path) {
+		return parser.ParseJSON(src, path)
+	}
+	return parser.ParseHCL(src, path)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+func TestManifestLoader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "modpacker-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// rel returns the path of the file in dir relative
+	// to the working directory.
+	rel := func(name string) string {
+		p, err := filepath.Rel(cwd, filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	abs := func(name string) string {
+		return filepath.ToSlash(filepath.Join(dir, name))
+	}
+
+	files := map[string]string{
+		// Manifests are identified by their mod path.
+		"a.hcl": `
+import "b.hcl" {}
+import "sub/c.hcl" {}
+mod "a" { content = "" }
+`,
+		"b.hcl": `
+import "` + abs("sub/c.hcl") + `" {}
+mod "b" { content = "" }
+`,
+		"sub/c.hcl": `mod "c" { content = "" }`,
+		"x.hcl": `
+import "y.hcl" {}
+mod "x" { content = "" }
+`,
+		"y.hcl": `
+import "` + abs("x.hcl") + `" {}
+mod "y" { content = "" }
+`,
+	}
+	for name, src := range files {
+		fpath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fpath, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		Name  string
+		Paths []string
+		// Mods are the paths of the mods in loaded manifests.
+		Mods []string
+		// Cycle is the expected import cycle.
+		Cycle []string
+	}{
+		{
+			Name:  "Imports",
+			Paths: []string{rel("a.hcl")},
+			Mods:  []string{"c", "b", "a"},
+		},
+		{
+			Name:  "LoadedOnce",
+			Paths: []string{rel("sub/c.hcl"), filepath.Join(dir, "a.hcl"), rel("b.hcl")},
+			Mods:  []string{"c", "b", "a"},
+		},
+		{
+			Name:  "Cycle",
+			Paths: []string{rel("x.hcl")},
+			Cycle: []string{rel("x.hcl"), rel("y.hcl"), filepath.Join(dir, "x.hcl")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			ms, diags, ok := parseManifests(hclparse.NewParser(), tt.Paths, &VarFlags{})
+			if tt.Cycle != nil {
+				if ok || len(diags) != 1 || diags[0].Summary != "Import cycle" {
+					t.Fatalf("got %v, expected import cycle", diags)
+				}
+				cycle := strings.Join(tt.Cycle, " -> ")
+				if !strings.Contains(diags[0].Detail, cycle) {
+					t.Fatalf("got %q, expected cycle %q", diags[0].Detail, cycle)
+				}
+				return
+			}
+			if !ok {
+				t.Fatal(diags)
+			}
+			var mods []string
+			for _, m := range ms {
+				for _, mod := range m.Mods {
+					mods = append(mods, mod.Path)
+				}
+			}
+			if !reflect.DeepEqual(mods, tt.Mods) {
+				t.Fatalf("got %q, expected %q", mods, tt.Mods)
+			}
+		})
+	}
+}
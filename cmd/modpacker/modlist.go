@@ -10,8 +10,6 @@ import (
 	"github.com/google/subcommands"
 
 	"github.com/tie/internal/renameio"
-
-	"github.com/tie/modpacker/pack"
 )
 
 const modlistTemplate = `<!doctype html>
@@ -25,12 +23,13 @@ const modlistTemplate = `<!doctype html>
 type ModlistCommand struct {
 	OutputPath   string
 	DisableCache bool
+	Vars         VarFlags
 }
 
 func (*ModlistCommand) Name() string     { return "modlist" }
 func (*ModlistCommand) Synopsis() string { return "generate modlist page" }
 func (*ModlistCommand) Usage() string {
-	return `Usage: modpacker sums [-o sums.pack] [-nocache] [manifest paths]
+	return `Usage: modpacker modlist [-o modlist.html] [-nocache] [-var name=value] [-var-file file] [manifest paths]
 
 	Generates modlist page for all CurseForge mods.
 
@@ -41,6 +40,7 @@ Flags:
 func (cmd *ModlistCommand) SetFlags(fs *flag.FlagSet) {
 	fs.BoolVar(&cmd.DisableCache, "nocache", false, "disable filesystem cache")
 	fs.StringVar(&cmd.OutputPath, "o", "modlist.html", "modlist page output path")
+	cmd.Vars.SetFlags(fs)
 }
 
 func (cmd *ModlistCommand) Execute(ctx context.Context, fs *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
@@ -55,15 +55,13 @@ func (cmd *ModlistCommand) Execute(ctx context.Context, fs *flag.FlagSet, args .
 		paths = []string{defaultManifest}
 	}
 
-	ms, ok := parseManifests(paths)
+	_, mods, ok := loadManifests(paths, &cmd.Vars)
 	if !ok {
 		return subcommands.ExitFailure
 	}
 
-	modlist := pack.ModList(ms)
-
 	var buf bytes.Buffer
-	if err := tpl.Execute(&buf, modlist); err != nil {
+	if err := tpl.Execute(&buf, mods); err != nil {
 		log.Printf("execute template: %+v", err)
 		return subcommands.ExitFailure
 	}
@@ -4,20 +4,15 @@ import (
 	"context"
 	"flag"
 	"log"
-	"net/http"
+	"sort"
 
 	"github.com/google/subcommands"
 
 	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/zclconf/go-cty/cty"
 
-	"github.com/go-git/go-billy/v5"
-	"github.com/go-git/go-billy/v5/memfs"
-	"github.com/go-git/go-billy/v5/osfs"
-
 	"github.com/tie/internal/renameio"
 
-	"github.com/tie/modpacker/fetcher"
 	"github.com/tie/modpacker/modpacker"
 	"github.com/tie/modpacker/pack"
 )
@@ -25,24 +20,33 @@ import (
 type SumsCommand struct {
 	OutputPath   string
 	DisableCache bool
+	Jobs         int
+	Fetch        FetchFlags
+	Vars         VarFlags
 }
 
 func (*SumsCommand) Name() string     { return "sums" }
 func (*SumsCommand) Synopsis() string { return "generate checksum manifest" }
 func (*SumsCommand) Usage() string {
-	return `Usage: modpacker sums [-o sums.pack] [-nocache] [manifest paths]
+	return `Usage: modpacker sums [-o sums.pack] [-j n] [-timeout duration] [-retries n] [-nocache] [-var name=value] [-var-file file] [manifest paths]
 
 	Generates checksum manifest for all mods. The resulting manifest will contain
 	"check" block for each distinct mod from input manifests. That is,
 	adding the same mod to different paths won’t produce multiple "check" blocks.
 
+	Local files are checked too, so that compile fails if a file was changed
+	after the checksums were generated. Directories have no checksums.
+
 Flags:
 `
 }
 
 func (cmd *SumsCommand) SetFlags(fs *flag.FlagSet) {
 	fs.BoolVar(&cmd.DisableCache, "nocache", false, "disable filesystem cache")
+	fs.IntVar(&cmd.Jobs, "j", defaultJobs, "download up to `n` mods concurrently")
+	cmd.Fetch.SetFlags(fs)
 	fs.StringVar(&cmd.OutputPath, "o", "sums.hcl", "manifest output path")
+	cmd.Vars.SetFlags(fs)
 }
 
 func (cmd *SumsCommand) Execute(ctx context.Context, fs *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
@@ -51,27 +55,18 @@ func (cmd *SumsCommand) Execute(ctx context.Context, fs *flag.FlagSet, args ...i
 		paths = []string{defaultManifest}
 	}
 
-	ms, ok := parseManifests(paths)
+	_, mods, ok := loadManifests(paths, &cmd.Vars)
 	if !ok {
 		return subcommands.ExitFailure
 	}
 
-	var cacheDir billy.Filesystem
-	if !cmd.DisableCache {
-		cache, err := makeCache(programName)
-		if err != nil {
-			log.Printf("make cache: %+v", err)
-			return subcommands.ExitFailure
-		}
-		cacheDir = osfs.New(cache)
-	} else {
-		cacheDir = memfs.New()
-	}
-	c := http.Client{}
-	fetcher := fetcher.Fetcher{
-		Files:  cacheDir,
-		Client: &c,
+	cacheDir, cleanup, err := openCache(cmd.DisableCache)
+	if err != nil {
+		log.Printf("make cache: %+v", err)
+		return subcommands.ExitFailure
 	}
+	defer cleanup()
+	fetcher := cmd.Fetch.newFetcher(cacheDir)
 
 	f := hclwrite.NewEmptyFile()
 	body := f.Body()
@@ -79,15 +74,29 @@ func (cmd *SumsCommand) Execute(ctx context.Context, fs *flag.FlagSet, args ...i
 		Body: body,
 	}
 
-	for _, mod := range pack.ModList(ms) {
-		sums, err := fetcher.Sums(mod)
-		if err != nil {
-			log.Printf("sum %q mod: %+v", mod.Method, err)
-			return subcommands.ExitFailure
-		}
+	modSums := make([][]string, len(mods))
+	i, err := forEach(cmd.Jobs, len(mods), func(i int) error {
+		sums, err := fetcher.Sums(ctx, mods[i])
+		modSums[i] = sums
+		return err
+	})
+	if err != nil {
+		log.Printf("sum %q mod: %+v", mods[i].Method, err)
+		return subcommands.ExitFailure
+	}
+
+	// Mods with the same source share a check block.
+	seen := make(map[pack.ModID]bool, len(mods))
+	for i, mod := range mods {
+		sums := modSums[i]
 		if len(sums) <= 0 {
 			continue
 		}
+		id := pack.ModIDOf(mod)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
 		sb.Add(mod, sums)
 	}
 
@@ -133,6 +142,70 @@ func (b *SumsBuilder) Add(m modpacker.Mod, sums []string) {
 		body.SetAttributeValue("fileID", fileID)
 	}
 
+	if p := m.Project; p != "" {
+		project := cty.StringVal(p)
+		body.SetAttributeValue("project", project)
+	}
+
+	if v := m.Version; v != "" {
+		version := cty.StringVal(v)
+		body.SetAttributeValue("version", version)
+	}
+
+	if r := m.Repo; r != "" {
+		repo := cty.StringVal(r)
+		body.SetAttributeValue("repo", repo)
+	}
+
+	if t := m.Tag; t != "" {
+		tag := cty.StringVal(t)
+		body.SetAttributeValue("tag", tag)
+	}
+
+	if a := m.Asset; a != "" {
+		asset := cty.StringVal(a)
+		body.SetAttributeValue("asset", asset)
+	}
+
+	if r := m.Repository; r != "" {
+		repository := cty.StringVal(r)
+		body.SetAttributeValue("repository", repository)
+	}
+
+	if c := m.Coordinates; c != "" {
+		coordinates := cty.StringVal(c)
+		body.SetAttributeValue("coordinates", coordinates)
+	}
+
+	if u := m.URL; u != "" {
+		url := cty.StringVal(u)
+		body.SetAttributeValue("url", url)
+	}
+
+	if r := m.Ref; r != "" {
+		ref := cty.StringVal(r)
+		body.SetAttributeValue("ref", ref)
+	}
+
+	if c := m.Commit; c != "" {
+		commit := cty.StringVal(c)
+		body.SetAttributeValue("commit", commit)
+	}
+
+	if p := m.RepoPath; p != "" {
+		repoPath := cty.StringVal(p)
+		body.SetAttributeValue("path", repoPath)
+	}
+
+	names := make([]string, 0, len(m.Attrs))
+	for name := range m.Attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		body.SetAttributeValue(name, cty.StringVal(m.Attrs[name]))
+	}
+
 	vals := make([]cty.Value, len(sums))
 	for i, sum := range sums {
 		vals[i] = cty.StringVal(sum)
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/tie/internal/robustio"
+
+	"github.com/tie/modpacker/pack/hclspec"
+)
+
+// stringsFlag is a flag.Value that accumulates values
+// from repeated flags.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+// VarFlags contains input variables values set from command line.
+type VarFlags struct {
+	Vars  stringsFlag
+	Files stringsFlag
+}
+
+func (v *VarFlags) SetFlags(fs *flag.FlagSet) {
+	fs.Var(&v.Vars, "var", "set input variable (`name=value`)")
+	fs.Var(&v.Files, "var-file", "set input variables from `file`")
+}
+
+// variable is an input variable value along with its source range.
+// Range is nil for variables set with -var flag.
+type variable struct {
+	Value cty.Value
+	Range *hcl.Range
+}
+
+// values returns input variables from variable files and -var flags.
+// Values from -var flags take precedence over files.
+func (v *VarFlags) values(parser *hclparse.Parser) (map[string]variable, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+
+	vars := make(map[string]variable)
+
+	ctx := &hcl.EvalContext{
+		Functions: hclspec.Functions(),
+	}
+	for _, fpath := range v.Files {
+		src, err := robustio.ReadFile(fpath)
+		if err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Failed to read variables file",
+				Detail:   fmt.Sprintf("Cannot read %q: %v.", fpath, err),
+			})
+			continue
+		}
+		file, parseDiags := parseFile(parser, src, fpath)
+		diags = append(diags, parseDiags...)
+		if parseDiags.HasErrors() {
+			continue
+		}
+		attrs, attrsDiags := file.Body.JustAttributes()
+		diags = append(diags, attrsDiags...)
+		for name, attr := range attrs {
+			val, valDiags := attr.Expr.Value(ctx)
+			diags = append(diags, valDiags...)
+			vars[name] = variable{
+				Value: val,
+				Range: attr.NameRange.Ptr(),
+			}
+		}
+	}
+
+	for _, s := range v.Vars {
+		i := strings.IndexByte(s, '=')
+		if i <= 0 {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid -var option",
+				Detail:   fmt.Sprintf("The given -var option %q is not in name=value format.", s),
+			})
+			continue
+		}
+		name, val := s[:i], s[i+1:]
+		vars[name] = variable{
+			Value: cty.StringVal(val),
+		}
+	}
+
+	return vars, diags
+}
+
+// checkVariables reports input variables that are not declared
+// in any of the manifests. Undeclared values in variable files
+// are allowed since the files may be shared between modpacks.
+func checkVariables(vars map[string]variable, ms []hclspec.Manifest) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		declared := false
+		for _, m := range ms {
+			if _, ok := m.Variables[name]; ok {
+				declared = true
+				break
+			}
+		}
+		if declared {
+			continue
+		}
+		v := vars[name]
+		severity := hcl.DiagError
+		if v.Range != nil {
+			severity = hcl.DiagWarning
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: severity,
+			Summary:  "Undeclared variable",
+			Detail:   fmt.Sprintf("A value was given for variable %q that is not declared in manifests.", name),
+			Subject:  v.Range,
+		})
+	}
+	return diags
+}
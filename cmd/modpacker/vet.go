@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/google/subcommands"
+
+	"github.com/tie/modpacker/pack"
+)
+
+type VetCommand struct {
+	Vars VarFlags
+}
+
+func (*VetCommand) Name() string     { return "vet" }
+func (*VetCommand) Synopsis() string { return "check manifests for mistakes" }
+func (*VetCommand) Usage() string {
+	return `Usage: modpacker vet [-var name=value] [-var-file file] [manifest paths]
+
+	Checks manifests without downloading mods. In addition to decoding
+	errors, it reports
+	  - conflicting mod paths;
+	  - absolute paths and paths outside of the modpack root;
+	  - unknown methods and actions;
+	  - curse mods without projectID or fileID;
+	  - modrinth mods without project or version;
+	  - github mods without repo, tag or asset;
+	  - maven mods without valid coordinates;
+	  - git mods without url and ref or commit;
+	  - remote mods without checksums;
+	  - check blocks that do not match any mod (as warnings).
+
+	Exits with non-zero status if any errors are found.
+
+Flags:
+`
+}
+
+func (cmd *VetCommand) SetFlags(fs *flag.FlagSet) {
+	cmd.Vars.SetFlags(fs)
+}
+
+func (cmd *VetCommand) Execute(ctx context.Context, fs *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	paths := fs.Args()
+	if len(paths) <= 0 {
+		paths = []string{defaultManifest}
+	}
+
+	_, _, ok := loadManifests(paths, &cmd.Vars, pack.Vet)
+	if !ok {
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
@@ -1,9 +1,9 @@
 package fetcher
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -13,6 +13,24 @@ import (
 	"github.com/tie/modpacker/modpacker"
 )
 
+func init() {
+	Register(modpacker.MethodCurse, Remote{
+		CachePath: curseCachePath,
+		Fetch:     curseFetch,
+		Problems:  curseProblems,
+	})
+}
+
+func curseProblems(m modpacker.Mod) []Problem {
+	if m.ProjectID <= 0 || m.FileID <= 0 {
+		return []Problem{{
+			Summary: "Missing CurseForge file",
+			Detail:  "Mods with \"curse\" method require positive projectID and fileID attributes.",
+		}}
+	}
+	return nil
+}
+
 func curseURL(projectID, fileID int) string {
 	u := "https://addons-ecs.forgesvc.net/api/v2/addon/%d/file/%d/download-url"
 	return fmt.Sprintf(u, projectID, fileID)
@@ -24,27 +42,19 @@ func curseCachePath(fs billy.Basic, m modpacker.Mod) (dir, base string) {
 	return fs.Join("curse", projectID), fileID
 }
 
-func curseFetchURL(c *http.Client, m modpacker.Mod) (string, error) {
+func curseFetch(ctx context.Context, dl *Fetcher, m modpacker.Mod) (Source, error) {
 	u := curseURL(m.ProjectID, m.FileID)
-	resp, err := c.Get(u)
-	if err != nil {
-		return "", err
-	}
-	r := resp.Body
-	defer func() {
-		err := r.Close()
-		if err != nil {
-			log.Printf("close %q: %+v", u, err)
+	var rawurl string
+	err := dl.Get(ctx, u, func(resp *http.Response) error {
+		// Don’t read responses larger than 1KiB.
+		lr := io.LimitReader(resp.Body, 1024)
+
+		var b strings.Builder
+		if _, err := io.Copy(&b, lr); err != nil {
+			return err
 		}
-	}()
-
-	// Don’t read responses larger than 1KiB.
-	lr := io.LimitReader(r, 1024)
-
-	var b strings.Builder
-	if _, err := io.Copy(&b, lr); err != nil {
-		return "", err
-	}
-	rawurl := b.String()
-	return rawurl, nil
+		rawurl = b.String()
+		return nil
+	})
+	return Source{URL: rawurl}, err
 }
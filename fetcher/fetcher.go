@@ -2,9 +2,11 @@ package fetcher
 
 import (
 	"bufio"
+	"context"
 	"crypto/md5"
 	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
 	"errors"
 	"fmt"
 	"hash"
@@ -13,11 +15,13 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/crypto/sha3"
 
 	"github.com/go-git/go-billy/v5"
-	"github.com/go-git/go-billy/v5/osfs"
 
 	"github.com/tie/modpacker/modpacker"
 )
@@ -25,12 +29,17 @@ import (
 var (
 	ErrSumsMismatch     = errors.New("checksum mismatch")
 	ErrUnknownModMethod = errors.New("unknown mod method")
+	ErrNotDir           = errors.New("mod is not a directory")
+	ErrNotFile          = errors.New("mod is not a file")
+	ErrContentLength    = errors.New("content length mismatch")
 )
 
-type (
-	cacheFunc func(billy.Basic, modpacker.Mod) (dir, base string)
-	fetchFunc func(*http.Client, modpacker.Mod) (string, error)
-)
+func init() {
+	Register(modpacker.MethodHTTP, Remote{
+		CachePath: httpCachePath,
+		Fetch:     httpFetch,
+	})
+}
 
 func httpCachePath(fs billy.Basic, m modpacker.Mod) (dir, base string) {
 	// Good enough is good enough.
@@ -39,99 +48,97 @@ func httpCachePath(fs billy.Basic, m modpacker.Mod) (dir, base string) {
 	return "http", fs.Join(hex[:2], hex)
 }
 
-func httpFetchURL(c *http.Client, m modpacker.Mod) (string, error) {
-	return m.File, nil
+func httpFetch(ctx context.Context, dl *Fetcher, m modpacker.Mod) (Source, error) {
+	return Source{URL: m.File}, nil
 }
 
+// Fetcher downloads mods to the cache. It is safe for concurrent use
+// if the cache filesystem is. Concurrent downloads of the same cache
+// entry are serialized.
 type Fetcher struct {
 	Files  billy.Filesystem
 	Client *http.Client
-}
 
-func (dl *Fetcher) Sums(m modpacker.Mod) ([]string, error) {
-	switch m.Method {
-	case modpacker.MethodCurse:
-		return dl.sumsGeneric(m, curseCachePath, curseFetchURL)
-	case modpacker.MethodOptifine:
-		return dl.sumsGeneric(m, optifineCachePath, optifineFetchURL)
-	case modpacker.MethodHTTP:
-		return dl.sumsGeneric(m, httpCachePath, httpFetchURL)
-	case modpacker.MethodFile:
-		// TODO should we check files integrity?
-		return nil, nil
-	}
-	return nil, ErrUnknownModMethod
+	// Timeout limits the time of each HTTP request, including
+	// reading the response body. Zero means no timeout.
+	Timeout time.Duration
+	// Retries is the number of times a request is retried after
+	// transient errors, with exponential backoff between retries.
+	Retries int
+
+	// ModrinthURL is the base URL of Modrinth API.
+	// If empty, DefaultModrinthURL is used.
+	ModrinthURL string
+	// GitHubURL is the base URL of GitHub API.
+	// If empty, DefaultGitHubURL is used.
+	GitHubURL string
+	// GitHubToken is the token for GitHub API requests.
+	// If empty, requests are not authenticated.
+	GitHubToken string
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+	// refs contain git refs resolved by the fetcher, so that
+	// a ref is fetched at most once and resolves to the same
+	// commit for all mods.
+	refs map[gitRef]string
 }
 
-func (dl *Fetcher) Cache(m modpacker.Mod) error {
-	switch m.Method {
-	case modpacker.MethodCurse:
-		return dl.cacheGeneric(m, curseCachePath, curseFetchURL)
-	case modpacker.MethodOptifine:
-		return dl.cacheGeneric(m, optifineCachePath, optifineFetchURL)
-	case modpacker.MethodHTTP:
-		return dl.cacheGeneric(m, httpCachePath, httpFetchURL)
-	case modpacker.MethodFile:
-		return nil
+// Sums returns the checksums of mod m.
+func (dl *Fetcher) Sums(ctx context.Context, m modpacker.Mod) ([]string, error) {
+	method, ok := Lookup(m.Method)
+	if !ok {
+		return nil, ErrUnknownModMethod
 	}
-	return ErrUnknownModMethod
+	return method.Sums(ctx, dl, m)
 }
 
-func (dl *Fetcher) Open(m modpacker.Mod) (billy.File, error) {
-	switch m.Method {
-	case modpacker.MethodCurse:
-		return dl.downloadGeneric(m, curseCachePath, curseFetchURL)
-	case modpacker.MethodOptifine:
-		return dl.downloadGeneric(m, optifineCachePath, optifineFetchURL)
-	case modpacker.MethodHTTP:
-		return dl.downloadGeneric(m, httpCachePath, httpFetchURL)
-	case modpacker.MethodFile:
-		path := filepath.FromSlash(m.File)
-		f, err := osfs.New("").Open(path)
-		if err != nil {
-			return nil, err
-		}
-		return f, err
+// Cache downloads mod m to the cache.
+func (dl *Fetcher) Cache(ctx context.Context, m modpacker.Mod) error {
+	method, ok := Lookup(m.Method)
+	if !ok {
+		return ErrUnknownModMethod
 	}
-	return nil, ErrUnknownModMethod
+	return method.Cache(ctx, dl, m)
 }
 
-func (dl *Fetcher) sumsGeneric(m modpacker.Mod, cachePath cacheFunc, fetchURL fetchFunc) ([]string, error) {
-	err := dl.cacheGeneric(m, cachePath, fetchURL)
-	if err != nil {
-		return nil, err
+// Open opens the file of mod m.
+func (dl *Fetcher) Open(ctx context.Context, m modpacker.Mod) (billy.File, error) {
+	method, ok := Lookup(m.Method)
+	if !ok {
+		return nil, ErrUnknownModMethod
 	}
-	dir, base := cachePath(dl.Files, m)
-	return dl.readSums(dir, base)
+	return method.Open(ctx, dl, m)
 }
 
-func (dl *Fetcher) cacheGeneric(m modpacker.Mod, cachePath cacheFunc, fetchURL fetchFunc) error {
-	dir, base := cachePath(dl.Files, m)
-	_, err := dl.statData(dir, base)
-	if !errors.Is(err, os.ErrNotExist) {
-		return err
+// OpenDir returns the filesystem rooted at the directory of mod m.
+func (dl *Fetcher) OpenDir(ctx context.Context, m modpacker.Mod) (billy.Filesystem, error) {
+	method, ok := Lookup(m.Method)
+	if !ok {
+		return nil, ErrUnknownModMethod
 	}
-	rawurl, err := fetchURL(dl.Client, m)
-	if err != nil {
-		return err
+	dm, ok := method.(DirMethod)
+	if !ok {
+		return nil, ErrNotDir
 	}
-	return dl.downloadFile(rawurl, dir, base)
+	return dm.OpenDir(ctx, dl, m)
 }
 
-func (dl *Fetcher) downloadGeneric(m modpacker.Mod, cachePath cacheFunc, fetchURL fetchFunc) (billy.File, error) {
-	dir, base := cachePath(dl.Files, m)
-	f, err := dl.getFile(dir, base, m.Sums)
-	if !errors.Is(err, os.ErrNotExist) {
-		return f, err
-	}
-	rawurl, err := fetchURL(dl.Client, m)
-	if err != nil {
-		return nil, err
+// lock locks the cache entry and returns the function that unlocks it.
+func (dl *Fetcher) lock(dir, base string) func() {
+	key := dl.Files.Join(dir, base)
+	dl.mu.Lock()
+	if dl.locks == nil {
+		dl.locks = make(map[string]*sync.Mutex)
 	}
-	if err := dl.downloadFile(rawurl, dir, base); err != nil {
-		return nil, err
+	l, ok := dl.locks[key]
+	if !ok {
+		l = &sync.Mutex{}
+		dl.locks[key] = l
 	}
-	return dl.getFile(dir, base, m.Sums)
+	dl.mu.Unlock()
+	l.Lock()
+	return l.Unlock
 }
 
 func (dl *Fetcher) getFile(dir, base string, sums []string) (billy.File, error) {
@@ -170,27 +177,64 @@ func (dl *Fetcher) readSums(dir, base string) ([]string, error) {
 }
 
 func (dl *Fetcher) verifySums(sums []string, dir, base string) error {
-	l := len(sums)
-	if l <= 0 {
+	if len(sums) <= 0 {
 		return nil
 	}
-	sumsMap := make(map[string]struct{}, l)
-	err := dl.withSums(dir, base, os.O_RDONLY, func(f billy.File) error {
-		defer func() {
-			cerr := f.Close()
-			if cerr != nil {
-				log.Printf("close %q: %+v", f.Name(), cerr)
-			}
-		}()
-		s := bufio.NewScanner(f)
-		for s.Scan() {
-			sum := s.Text()
-			sumsMap[sum] = struct{}{}
-		}
-		return s.Err()
-	})
+	actual, err := dl.cachedSums(dir, base)
+	if err != nil {
+		return err
+	}
+	return matchSums(sums, actual)
+}
+
+// cachedSums returns the checksums of the cache entry. Entries cached
+// by older versions may lack checksums for some of the hashes, so the
+// data is rehashed and the checksums are rewritten in that case.
+func (dl *Fetcher) cachedSums(dir, base string) ([]string, error) {
+	sums, err := dl.readSums(dir, base)
 	if err != nil {
+		return nil, err
+	}
+	if hasAllHashes(sums) {
+		return sums, nil
+	}
+	err = dl.withData(dir, base, os.O_RDONLY, func(f billy.File) error {
+		defer f.Close()
+		sums, err = Hash(f)
 		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := dl.writeSums(dir, base, sums); err != nil {
+		return nil, err
+	}
+	return sums, nil
+}
+
+// hasAllHashes reports whether sums contain checksums
+// for all hashes in hashNames.
+func hasAllHashes(sums []string) bool {
+	names := make(map[string]bool, len(sums))
+	for _, sum := range sums {
+		if i := strings.IndexByte(sum, ':'); i != -1 {
+			names[sum[:i]] = true
+		}
+	}
+	for _, name := range hashNames {
+		if !names[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// matchSums returns ErrSumsMismatch if any of expected sums
+// is missing in the actual sums.
+func matchSums(sums, actual []string) error {
+	sumsMap := make(map[string]struct{}, len(actual))
+	for _, sum := range actual {
+		sumsMap[sum] = struct{}{}
 	}
 	for _, sum := range sums {
 		if _, ok := sumsMap[sum]; ok {
@@ -201,68 +245,136 @@ func (dl *Fetcher) verifySums(sums []string, dir, base string) error {
 	return nil
 }
 
-func (dl *Fetcher) downloadFile(rawurl, dir, base string) error {
-	hashNames := []string{
-		"md5",
-		"sha1",
-		"sha256",
-		"keccak256",
+// verifyFile checks that the file matches sums and rewinds it
+// to the beginning.
+func verifyFile(f billy.File, sums []string) error {
+	if len(sums) <= 0 {
+		return nil
 	}
-	hashes := []hash.Hash{
+	actual, err := Hash(f)
+	if err != nil {
+		return err
+	}
+	if err := matchSums(sums, actual); err != nil {
+		return fmt.Errorf("%q: %w", f.Name(), err)
+	}
+	_, err = f.Seek(0, io.SeekStart)
+	return err
+}
+
+// hashNames are the names of hashes in checksums.
+var hashNames = []string{
+	"md5",
+	"sha1",
+	"sha256",
+	"sha512",
+	"keccak256",
+}
+
+// newHashes returns hashes in the order of hashNames.
+func newHashes() []hash.Hash {
+	return []hash.Hash{
 		md5.New(),
 		sha1.New(),
 		sha256.New(),
+		sha512.New(),
 		sha3.New256(),
 	}
-	nhashes := len(hashes)
-	flags := os.O_WRONLY | os.O_TRUNC | os.O_CREATE
-	err := dl.withData(dir, base, flags, func(f billy.File) (err error) {
-		defer func() {
-			cerr := f.Close()
-			if err == nil {
-				err = cerr
-			}
-		}()
-		l := nhashes
-		ww := make([]io.Writer, l+1)
-		for i, h := range hashes {
-			ww[i] = h
-		}
-		ww[l] = f
-		w := io.MultiWriter(ww...)
-		return dl.fetchFile(w, rawurl)
-	})
-	if err != nil {
-		return err
-	}
-	sums := make([]string, nhashes)
+}
+
+func formatSums(hashes []hash.Hash) []string {
+	sums := make([]string, len(hashes))
 	for i, name := range hashNames {
 		sums[i] = fmt.Sprintf("%s:%x", name, hashes[i].Sum(nil))
 	}
-	return dl.writeSums(dir, base, sums)
+	return sums
+}
+
+// Hash returns checksums of the data read from r in the same
+// format as the checksums of downloaded files.
+func Hash(r io.Reader) ([]string, error) {
+	hashes := newHashes()
+	ww := make([]io.Writer, len(hashes))
+	for i, h := range hashes {
+		ww[i] = h
+	}
+	if _, err := io.Copy(io.MultiWriter(ww...), r); err != nil {
+		return nil, err
+	}
+	return formatSums(hashes), nil
 }
 
-func (dl *Fetcher) fetchFile(w io.Writer, rawurl string) error {
-	resp, err := dl.Client.Get(rawurl)
+// downloadFile downloads the file to the cache and writes its checksums.
+// The file is downloaded to a partial file that is renamed into place
+// after the checksums are written, so that a failed download never
+// leaves the cache entry in inconsistent state.
+//
+// If the server specifies a validator for the file, the partial file
+// is kept on errors and the next download resumes it. See partial.
+//
+// File that does not match the checksums of the source is discarded.
+func (dl *Fetcher) downloadFile(ctx context.Context, src Source, dir, base string) error {
+	rawurl := src.URL
+	p := &partial{
+		dl:     dl,
+		url:    rawurl,
+		header: src.Header,
+		dir:    dir,
+		base:   base,
+	}
+	err := dl.send(ctx, rawurl, p.prepare, func(resp *http.Response) error {
+		return p.write(resp)
+	})
+	if p.restart(err) {
+		// Resume failed, so we start from scratch.
+		p.remove()
+		err = dl.send(ctx, rawurl, p.prepare, func(resp *http.Response) error {
+			return p.write(resp)
+		})
+	}
 	if err != nil {
+		if !p.resumable() {
+			p.remove()
+		}
 		return err
 	}
-	r := resp.Body
-	defer func() {
-		err := r.Close()
-		if err != nil {
-			log.Printf("close %q: %+v", rawurl, err)
-		}
-	}()
-	if _, err := io.Copy(w, r); err != nil {
+	sums := formatSums(p.hashes)
+	if err := matchSums(src.Sums, sums); err != nil {
+		p.remove()
+		return fmt.Errorf("%s: %w", rawurl, err)
+	}
+	if err := dl.writeSums(dir, base, sums); err != nil {
 		return err
 	}
+	if err := dl.Files.Rename(p.path(), dl.entryPath(dir, base, "dat")); err != nil {
+		return err
+	}
+	p.remove()
 	return nil
 }
 
+// tempFile creates a temporary file next to the cache entry.
+func (dl *Fetcher) tempFile(dir, base string) (billy.File, error) {
+	// Base may contain directories, e.g. for HTTP cache.
+	fpath := dl.Files.Join(dir, base)
+	return dl.Files.TempFile(filepath.Dir(fpath), filepath.Base(fpath)+".")
+}
+
+func (dl *Fetcher) removeFile(fpath string) {
+	err := dl.Files.Remove(fpath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("remove %q: %+v", fpath, err)
+	}
+}
+
+// writeSums atomically replaces the checksums of the cache entry.
 func (dl *Fetcher) writeSums(dir, base string, sums []string) error {
-	flags := os.O_WRONLY | os.O_APPEND | os.O_CREATE
-	return dl.withSums(dir, base, flags, func(f billy.File) (err error) {
+	f, err := dl.tempFile(dir, base)
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	err = func() (err error) {
 		defer func() {
 			cerr := f.Close()
 			if err == nil {
@@ -270,20 +382,21 @@ func (dl *Fetcher) writeSums(dir, base string, sums []string) error {
 			}
 		}()
 		w := bufio.NewWriter(f)
-		defer func() {
-			ferr := w.Flush()
-			if err == nil {
-				err = ferr
-			}
-		}()
 		for _, sum := range sums {
-			_, err = fmt.Fprintf(w, "%s\r\n", sum)
-			if err != nil {
-				break
+			if _, err := fmt.Fprintf(w, "%s\r\n", sum); err != nil {
+				return err
 			}
 		}
+		return w.Flush()
+	}()
+	if err == nil {
+		err = dl.Files.Rename(tmp, dl.entryPath(dir, base, "sum"))
+	}
+	if err != nil {
+		dl.removeFile(tmp)
 		return err
-	})
+	}
+	return nil
 }
 
 func (dl *Fetcher) statData(dir, base string) (os.FileInfo, error) {
@@ -291,9 +404,12 @@ func (dl *Fetcher) statData(dir, base string) (os.FileInfo, error) {
 }
 
 func (dl *Fetcher) statFile(dir, base, ext string) (os.FileInfo, error) {
+	return dl.Files.Stat(dl.entryPath(dir, base, ext))
+}
+
+func (dl *Fetcher) entryPath(dir, base, ext string) string {
 	fname := fmt.Sprintf("%s.%s", base, ext)
-	fpath := dl.Files.Join(dir, fname)
-	return dl.Files.Stat(fpath)
+	return dl.Files.Join(dir, fname)
 }
 
 func (dl *Fetcher) withData(dir, base string, flag int, fn func(billy.File) error) error {
@@ -308,8 +424,7 @@ func (dl *Fetcher) withFile(dir, base, ext string, flag int, fn func(billy.File)
 	if err := dl.Files.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
-	fname := fmt.Sprintf("%s.%s", base, ext)
-	fpath := dl.Files.Join(dir, fname)
+	fpath := dl.entryPath(dir, base, ext)
 	f, err := dl.Files.OpenFile(fpath, flag, 0644)
 	if err != nil {
 		return err
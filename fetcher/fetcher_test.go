@@ -0,0 +1,92 @@
+package fetcher
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+
+	"github.com/tie/modpacker/modpacker"
+)
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "modpacker-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Error(err)
+		}
+	})
+	return dir
+}
+
+// testFetcher returns the fetcher with in-memory cache that uses
+// the client of test server srv.
+func testFetcher(srv *httptest.Server) *Fetcher {
+	return &Fetcher{
+		Files:  memfs.New(),
+		Client: srv.Client(),
+	}
+}
+
+// readMod opens mod m and returns its content.
+func readMod(ctx context.Context, dl *Fetcher, m modpacker.Mod) (string, error) {
+	f, err := dl.Open(ctx, m)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	return string(b), err
+}
+
+func TestStaleSums(t *testing.T) {
+	const content = "mod"
+	sums, err := Hash(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dl := &Fetcher{Files: memfs.New()}
+	m := modpacker.Mod{
+		Method: modpacker.MethodHTTP,
+		File:   "http://example.com/mod.jar",
+		Sums:   sums,
+	}
+	// Older versions did not store sha512 and keccak256 checksums.
+	dir, base := httpCachePath(dl.Files, m)
+	err = dl.withData(dir, base, os.O_WRONLY|os.O_CREATE, func(f billy.File) error {
+		defer f.Close()
+		_, err := f.Write([]byte(content))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dl.writeSums(dir, base, sums[:3]); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readMod(context.Background(), dl, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != content {
+		t.Fatalf("got %q, expected %q", got, content)
+	}
+	stored, err := dl.readSums(dir, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasAllHashes(stored) {
+		t.Fatalf("checksums were not rewritten: %q", stored)
+	}
+}
@@ -0,0 +1,345 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+
+	"github.com/tie/modpacker/modpacker"
+)
+
+var (
+	ErrNoRevision    = errors.New("git mod has no ref or commit")
+	ErrInvalidCommit = errors.New("git commit is not a full commit hash")
+)
+
+func init() {
+	Register(modpacker.MethodGit, gitMethod{})
+}
+
+// gitRefSpecs mirror branches and tags of the remote repository.
+var gitRefSpecs = []config.RefSpec{
+	"+refs/heads/*:refs/heads/*",
+	"+refs/tags/*:refs/tags/*",
+}
+
+// gitMethod takes files from git repositories. Repositories are
+// mirrored to the cache, and the tree of each commit is checked out
+// to the directory named after the commit hash.
+//
+// Mods with commit attribute are fetched from the remote only if the
+// commit is not in the cache. Refs are resolved on each fetch.
+type gitMethod struct{}
+
+func (gitMethod) Cache(ctx context.Context, dl *Fetcher, m modpacker.Mod) error {
+	_, err := dl.gitCheckout(ctx, m)
+	return err
+}
+
+// Sums returns the checksums of the file in repository,
+// or nil if the mod is a directory.
+func (gitMethod) Sums(ctx context.Context, dl *Fetcher, m modpacker.Mod) ([]string, error) {
+	fpath, err := dl.gitCheckout(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := dl.Files.Stat(fpath)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return nil, nil
+	}
+	f, err := dl.Files.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Hash(f)
+}
+
+func (gitMethod) Open(ctx context.Context, dl *Fetcher, m modpacker.Mod) (billy.File, error) {
+	fpath, err := dl.gitCheckout(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := dl.Files.Stat(fpath)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return nil, ErrNotFile
+	}
+	f, err := dl.Files.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyFile(f, m.Sums); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func (gitMethod) OpenDir(ctx context.Context, dl *Fetcher, m modpacker.Mod) (billy.Filesystem, error) {
+	fpath, err := dl.gitCheckout(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := dl.Files.Stat(fpath)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, ErrNotDir
+	}
+	return dl.Files.Chroot(fpath)
+}
+
+func (gitMethod) Vet(m modpacker.Mod) []Problem {
+	var problems []Problem
+	switch {
+	case m.URL == "" || m.Ref == "" && m.Commit == "":
+		problems = append(problems, Problem{
+			Summary: "Missing git revision",
+			Detail:  "Mods with \"git\" method require url attribute and either ref or commit attribute.",
+		})
+	case m.Commit == "":
+		problems = append(problems, Problem{
+			Summary: "Unpinned git revision",
+			Detail:  fmt.Sprintf("Ref %q may point to different commits over time. Set commit attribute to pin the revision.", m.Ref),
+			Warning: true,
+		})
+	case !IsCommitHash(m.Commit):
+		problems = append(problems, Problem{
+			Summary: "Invalid git commit",
+			Detail:  fmt.Sprintf("Commit %q is not a full lowercase hexadecimal commit hash.", m.Commit),
+		})
+	}
+	if clean := path.Clean(m.RepoPath); path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		problems = append(problems, Problem{
+			Summary: "Invalid repository path",
+			Detail:  fmt.Sprintf("Path %q refers to a location outside of the repository.", m.RepoPath),
+		})
+	}
+	return problems
+}
+
+// gitRef is the ref of the remote repository.
+type gitRef struct {
+	URL string
+	Ref string
+}
+
+// gitCachePath returns the cache directory of the repository.
+func gitCachePath(fs billy.Basic, rawurl string) string {
+	sum := sha1.Sum([]byte(rawurl))
+	hex := fmt.Sprintf("%x", sum)
+	return fs.Join("git", hex[:2], hex)
+}
+
+// IsCommitHash reports whether s is a full SHA-1 commit hash
+// in lowercase hexadecimal, as printed by git.
+func IsCommitHash(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// gitCheckout checks out the mod commit to the cache and returns
+// the path of the mod file or directory in the cache.
+func (dl *Fetcher) gitCheckout(ctx context.Context, m modpacker.Mod) (string, error) {
+	if m.Ref == "" && m.Commit == "" {
+		return "", ErrNoRevision
+	}
+	// Commit is used as the name of the tree directory,
+	// so anything but a full hash could escape the cache
+	// or never match the checked out tree.
+	if m.Commit != "" && !IsCommitHash(m.Commit) {
+		return "", fmt.Errorf("%q: %w", m.Commit, ErrInvalidCommit)
+	}
+
+	dir := gitCachePath(dl.Files, m.URL)
+	defer dl.lock(dir, "repo")()
+
+	// Paths outside of the tree are not allowed.
+	rel := filepath.FromSlash(path.Clean("/" + m.RepoPath))
+
+	commit := m.Commit
+	if commit == "" {
+		commit = dl.resolvedRef(gitRef{m.URL, m.Ref})
+	}
+	if commit != "" {
+		tree := dl.Files.Join(dir, commit)
+		if _, err := dl.Files.Stat(tree); err == nil {
+			return dl.Files.Join(tree, rel), nil
+		}
+	}
+
+	repo, err := dl.gitOpen(dir)
+	if err != nil {
+		return "", err
+	}
+	c, err := dl.gitResolve(ctx, repo, m.URL, m.Ref, commit)
+	if err != nil {
+		return "", err
+	}
+	if m.Commit == "" {
+		dl.setResolvedRef(gitRef{m.URL, m.Ref}, c.Hash.String())
+	}
+
+	tree := dl.Files.Join(dir, c.Hash.String())
+	if _, err := dl.Files.Stat(tree); errors.Is(err, os.ErrNotExist) {
+		if err := dl.gitWriteTree(c, tree); err != nil {
+			return "", err
+		}
+	} else if err != nil {
+		return "", err
+	}
+	return dl.Files.Join(tree, rel), nil
+}
+
+// gitOpen opens the bare repository in the cache directory,
+// initializing it if it does not exist.
+func (dl *Fetcher) gitOpen(dir string) (*git.Repository, error) {
+	fs, err := dl.Files.Chroot(dl.Files.Join(dir, "repo"))
+	if err != nil {
+		return nil, err
+	}
+	s := filesystem.NewStorage(fs, cache.NewObjectLRUDefault())
+	repo, err := git.Open(s, nil)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		return git.Init(s, nil)
+	}
+	return repo, err
+}
+
+func (dl *Fetcher) resolvedRef(ref gitRef) string {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	return dl.refs[ref]
+}
+
+func (dl *Fetcher) setResolvedRef(ref gitRef, commit string) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	if dl.refs == nil {
+		dl.refs = make(map[gitRef]string)
+	}
+	dl.refs[ref] = commit
+}
+
+// gitResolve returns the commit, or the commit that ref points to if
+// commit is empty. The remote repository is fetched unless the commit
+// is already in the cache.
+func (dl *Fetcher) gitResolve(ctx context.Context, repo *git.Repository, rawurl, ref, commit string) (*object.Commit, error) {
+	if commit != "" {
+		c, err := repo.CommitObject(plumbing.NewHash(commit))
+		if err == nil {
+			return c, nil
+		}
+	}
+
+	remote := git.NewRemote(repo.Storer, &config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{rawurl},
+	})
+	err := remote.FetchContext(ctx, &git.FetchOptions{
+		RefSpecs: gitRefSpecs,
+		Tags:     git.NoTags,
+		Force:    true,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil, fmt.Errorf("fetch %s: %w", rawurl, err)
+	}
+
+	rev := commit
+	if rev == "" {
+		rev = ref
+	}
+	h, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("%s: resolve %q: %w", rawurl, rev, err)
+	}
+	return repo.CommitObject(*h)
+}
+
+// gitWriteTree writes files of the commit to directory dir. Files are
+// written to a temporary directory that is renamed into place, so that
+// the directory is either complete or does not exist.
+//
+// Symbolic links and submodules are not supported and are skipped.
+func (dl *Fetcher) gitWriteTree(c *object.Commit, dir string) error {
+	tmp := dir + ".tmp"
+	if err := util.RemoveAll(dl.Files, tmp); err != nil {
+		return err
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return err
+	}
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if f.Mode != filemode.Regular && f.Mode != filemode.Executable {
+			return nil
+		}
+		return dl.gitWriteFile(f, dl.Files.Join(tmp, filepath.FromSlash(f.Name)))
+	})
+	if err == nil {
+		// Empty tree still has a directory.
+		err = dl.Files.MkdirAll(tmp, 0755)
+	}
+	if err == nil {
+		err = dl.Files.Rename(tmp, dir)
+	}
+	if err != nil {
+		if rerr := util.RemoveAll(dl.Files, tmp); rerr != nil {
+			log.Printf("remove %q: %+v", tmp, rerr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (dl *Fetcher) gitWriteFile(f *object.File, fpath string) error {
+	if err := dl.Files.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+		return err
+	}
+	r, err := f.Reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := dl.Files.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
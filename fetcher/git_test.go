@@ -0,0 +1,165 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/osfs"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/tie/modpacker/modpacker"
+)
+
+// testGitRepo creates a repository with a single commit on master
+// branch and returns its URL and the commit hash.
+func testGitRepo(t *testing.T, files map[string]string) (rawurl, commit string) {
+	t.Helper()
+
+	dir := tempDir(t)
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		fpath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fpath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := wt.Add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h, err := wt.Commit("initial commit", &git.CommitOptions{
+		Author: &object.Signature{
+			Name:  "test",
+			Email: "test@example.com",
+			When:  time.Unix(0, 0),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "file://" + filepath.ToSlash(dir), h.String()
+}
+
+func TestGitOpen(t *testing.T) {
+	rawurl, commit := testGitRepo(t, map[string]string{
+		"mod.txt":     "mod",
+		"dir/sub.txt": "sub",
+	})
+	dl := &Fetcher{Files: osfs.New(tempDir(t))}
+	ctx := context.Background()
+
+	tests := []struct {
+		Name string
+		Mod  modpacker.Mod
+	}{
+		{"Commit", modpacker.Mod{URL: rawurl, Commit: commit, RepoPath: "mod.txt"}},
+		{"Ref", modpacker.Mod{URL: rawurl, Ref: "master", RepoPath: "mod.txt"}},
+		{"Both", modpacker.Mod{URL: rawurl, Ref: "master", Commit: commit, RepoPath: "/mod.txt"}},
+		// Paths are relative to the repository root.
+		{"Escape", modpacker.Mod{URL: rawurl, Commit: commit, RepoPath: "../../mod.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			tt.Mod.Method = modpacker.MethodGit
+			f, err := dl.Open(ctx, tt.Mod)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			b, err := ioutil.ReadAll(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != "mod" {
+				t.Fatalf("got %q, expected %q", b, "mod")
+			}
+		})
+	}
+}
+
+func TestGitOpenDir(t *testing.T) {
+	rawurl, commit := testGitRepo(t, map[string]string{
+		"dir/sub.txt": "sub",
+	})
+	dl := &Fetcher{Files: osfs.New(tempDir(t))}
+	ctx := context.Background()
+
+	m := modpacker.Mod{
+		Method:   modpacker.MethodGit,
+		URL:      rawurl,
+		Commit:   commit,
+		RepoPath: "dir",
+	}
+	fs, err := dl.OpenDir(ctx, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := fs.Open("sub.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "sub" {
+		t.Fatalf("got %q, expected %q", b, "sub")
+	}
+
+	if _, err := dl.Open(ctx, m); !errors.Is(err, ErrNotFile) {
+		t.Fatalf("got %v, expected %v", err, ErrNotFile)
+	}
+}
+
+func TestGitInvalidCommit(t *testing.T) {
+	rawurl, commit := testGitRepo(t, map[string]string{
+		"mod.txt": "mod",
+	})
+	dl := &Fetcher{Files: osfs.New(tempDir(t))}
+	ctx := context.Background()
+
+	commits := []string{
+		commit[:7],
+		"../../../../../../etc",
+		"../" + commit[3:],
+		"ABCDEF0123456789ABCDEF0123456789ABCDEF01",
+	}
+	for _, c := range commits {
+		m := modpacker.Mod{
+			Method: modpacker.MethodGit,
+			URL:    rawurl,
+			Commit: c,
+		}
+		if _, err := dl.OpenDir(ctx, m); !errors.Is(err, ErrInvalidCommit) {
+			t.Errorf("commit %q: got %v, expected %v", c, err, ErrInvalidCommit)
+		}
+	}
+}
+
+func TestGitNoRevision(t *testing.T) {
+	dl := &Fetcher{Files: osfs.New(tempDir(t))}
+	m := modpacker.Mod{
+		Method: modpacker.MethodGit,
+		URL:    "file:///nonexistent",
+	}
+	if err := dl.Cache(context.Background(), m); !errors.Is(err, ErrNoRevision) {
+		t.Fatalf("got %v, expected %v", err, ErrNoRevision)
+	}
+}
@@ -0,0 +1,127 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+
+	"github.com/tie/modpacker/modpacker"
+)
+
+// DefaultGitHubURL is the base URL of GitHub API.
+const DefaultGitHubURL = "https://api.github.com"
+
+var ErrAssetNotFound = errors.New("release asset not found")
+
+func init() {
+	Register(modpacker.MethodGitHub, Remote{
+		CachePath: githubCachePath,
+		Fetch:     githubFetch,
+		Problems:  githubProblems,
+	})
+}
+
+func githubProblems(m modpacker.Mod) []Problem {
+	if m.Repo == "" || m.Tag == "" || m.Asset == "" {
+		return []Problem{{
+			Summary: "Missing GitHub release asset",
+			Detail:  "Mods with \"github\" method require repo, tag and asset attributes.",
+		}}
+	}
+	owner, name := SplitRepo(m.Repo)
+	if owner == "" || !isPathElem(owner) || !isPathElem(name) || !isPathElem(m.Tag) || !isPathElem(m.Asset) {
+		return []Problem{{
+			Summary: "Invalid GitHub release asset",
+			Detail:  "The repo attribute must be in \"owner/repo\" form, and the tag and asset attributes must not contain slashes or be \".\" or \"..\".",
+		}}
+	}
+	return nil
+}
+
+// githubRelease is the release object returned by GitHub API.
+type githubRelease struct {
+	Assets []githubAsset `json:"assets"`
+}
+
+type githubAsset struct {
+	Name string `json:"name"`
+	// URL is the API URL of the asset.
+	URL string `json:"url"`
+	// DownloadURL is the URL of the asset on the release page.
+	DownloadURL string `json:"browser_download_url"`
+}
+
+func githubURL(base, repo, tag string) string {
+	u := "%s/repos/%s/releases/tags/%s"
+	base = strings.TrimSuffix(base, "/")
+	owner, name := SplitRepo(repo)
+	repo = url.PathEscape(owner) + "/" + url.PathEscape(name)
+	return fmt.Sprintf(u, base, repo, url.PathEscape(tag))
+}
+
+// SplitRepo splits "owner/repo" repository name. If repo does not
+// contain a slash, owner is empty and name is repo.
+func SplitRepo(repo string) (owner, name string) {
+	i := strings.IndexByte(repo, '/')
+	if i == -1 {
+		return "", repo
+	}
+	return repo[:i], repo[i+1:]
+}
+
+func githubCachePath(fs billy.Basic, m modpacker.Mod) (dir, base string) {
+	owner, name := SplitRepo(m.Repo)
+	return fs.Join("github", owner, name, m.Tag), m.Asset
+}
+
+func githubFetch(ctx context.Context, dl *Fetcher, m modpacker.Mod) (Source, error) {
+	base := dl.GitHubURL
+	if base == "" {
+		base = DefaultGitHubURL
+	}
+	u := githubURL(base, m.Repo, m.Tag)
+	var r githubRelease
+	err := dl.send(ctx, u, func(req *http.Request) error {
+		req.Header.Set("Accept", "application/vnd.github.v3+json")
+		dl.githubAuth(req.Header)
+		return nil
+	}, func(resp *http.Response) error {
+		// Don’t read responses larger than 1MiB.
+		lr := io.LimitReader(resp.Body, 1024*1024)
+
+		r = githubRelease{}
+		return json.NewDecoder(lr).Decode(&r)
+	})
+	if err != nil {
+		return Source{}, err
+	}
+	for _, a := range r.Assets {
+		if a.Name != m.Asset {
+			continue
+		}
+		if dl.GitHubToken == "" {
+			return Source{URL: a.DownloadURL}, nil
+		}
+		// Assets of private repositories are available
+		// only from the API with authorization.
+		h := make(http.Header)
+		h.Set("Accept", "application/octet-stream")
+		dl.githubAuth(h)
+		return Source{URL: a.URL, Header: h}, nil
+	}
+	return Source{}, fmt.Errorf("%s %s: %q: %w", m.Repo, m.Tag, m.Asset, ErrAssetNotFound)
+}
+
+func (dl *Fetcher) githubAuth(h http.Header) {
+	if dl.GitHubToken == "" {
+		return
+	}
+	h.Set("Authorization", "token "+dl.GitHubToken)
+}
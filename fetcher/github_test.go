@@ -0,0 +1,114 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tie/modpacker/modpacker"
+)
+
+func TestGitHub(t *testing.T) {
+	const token = "secret"
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/repos/owner/repo/releases/tags/v1.0":
+			if auth != "" && auth != "token "+token {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(githubRelease{
+				Assets: []githubAsset{
+					{
+						Name:        "mod-sources.jar",
+						URL:         srv.URL + "/api/assets/1",
+						DownloadURL: srv.URL + "/download/mod-sources.jar",
+					},
+					{
+						Name:        "mod.jar",
+						URL:         srv.URL + "/api/assets/2",
+						DownloadURL: srv.URL + "/download/mod.jar",
+					},
+				},
+			})
+		case "/download/mod.jar":
+			_, _ = w.Write([]byte("public"))
+		case "/download/mod-sources.jar":
+			_, _ = w.Write([]byte("sources"))
+		case "/api/assets/2":
+			// API returns the asset itself only if it is asked
+			// for octet-stream, and JSON metadata otherwise.
+			if auth != "token "+token || r.Header.Get("Accept") != "application/octet-stream" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte("private"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		Name     string
+		Token    string
+		Asset    string
+		Expected string
+		Err      error
+	}{
+		{Name: "Public", Asset: "mod.jar", Expected: "public"},
+		{Name: "Sources", Asset: "mod-sources.jar", Expected: "sources"},
+		{Name: "Token", Token: token, Asset: "mod.jar", Expected: "private"},
+		{Name: "NotFound", Asset: "mod-api.jar", Err: ErrAssetNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			dl := testFetcher(srv)
+			dl.GitHubURL = srv.URL
+			dl.GitHubToken = tt.Token
+			m := modpacker.Mod{
+				Method: modpacker.MethodGitHub,
+				Repo:   "owner/repo",
+				Tag:    "v1.0",
+				Asset:  tt.Asset,
+			}
+			content, err := readMod(context.Background(), dl, m)
+			if tt.Err != nil {
+				if !errors.Is(err, tt.Err) {
+					t.Fatalf("got %v, expected %v", err, tt.Err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if content != tt.Expected {
+				t.Fatalf("got %q, expected %q", content, tt.Expected)
+			}
+		})
+	}
+}
+
+func TestSplitRepo(t *testing.T) {
+	tests := []struct {
+		Repo  string
+		Owner string
+		Name  string
+	}{
+		{"owner/repo", "owner", "repo"},
+		{"repo", "", "repo"},
+		{"owner/repo/extra", "owner", "repo/extra"},
+	}
+	for _, tt := range tests {
+		owner, name := SplitRepo(tt.Repo)
+		if owner != tt.Owner || name != tt.Name {
+			t.Errorf("SplitRepo(%q) = %q, %q; expected %q, %q", tt.Repo, owner, name, tt.Owner, tt.Name)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// retryDelay is the delay before the first retry.
+// It is doubled after each retry up to maxRetryDelay.
+var (
+	retryDelay    = time.Second
+	maxRetryDelay = 30 * time.Second
+)
+
+// StatusError is returned for HTTP responses with unexpected status.
+type StatusError struct {
+	URL        string
+	Status     string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: unexpected status %q", e.URL, e.Status)
+}
+
+// Get sends GET request and calls fn with the response. Requests that
+// fail with transient errors are retried, so fn may be called multiple
+// times.
+func (dl *Fetcher) Get(ctx context.Context, rawurl string, fn func(resp *http.Response) error) error {
+	return dl.send(ctx, rawurl, nil, fn)
+}
+
+// send is like Get, but calls prepare, if not nil, to modify the request
+// before each attempt.
+func (dl *Fetcher) send(ctx context.Context, rawurl string, prepare func(req *http.Request) error, fn func(resp *http.Response) error) error {
+	return dl.retry(ctx, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawurl, nil)
+		if err != nil {
+			return err
+		}
+		if prepare != nil {
+			if err := prepare(req); err != nil {
+				return err
+			}
+		}
+		resp, err := dl.Client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err := resp.Body.Close()
+			if err != nil {
+				log.Printf("close %q: %+v", rawurl, err)
+			}
+		}()
+		// Error pages must never be cached as files. Server
+		// errors are retried since they are usually transient.
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return &StatusError{
+				URL:        rawurl,
+				Status:     resp.Status,
+				StatusCode: resp.StatusCode,
+			}
+		}
+		return fn(resp)
+	})
+}
+
+// retry calls fn until it succeeds, fails with non-transient error or
+// the number of retries is exceeded. Each call is limited by the
+// fetcher’s timeout.
+func (dl *Fetcher) retry(ctx context.Context, fn func(ctx context.Context) error) error {
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		err := dl.attempt(ctx, fn)
+		if err == nil || attempt >= dl.Retries || !isTransient(err) {
+			return err
+		}
+		// Parent context is done, so the error is not transient.
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("retrying in %s: %+v", delay, err)
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+func (dl *Fetcher) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	if dl.Timeout <= 0 {
+		return fn(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, dl.Timeout)
+	defer cancel()
+	return fn(ctx)
+}
+
+// isTransient reports whether the request that failed with err
+// may succeed if retried.
+func isTransient(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode >= 500 || se.StatusCode == http.StatusTooManyRequests
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return ne.Timeout() || ne.Temporary()
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+
+	"github.com/tie/modpacker/modpacker"
+)
+
+func init() {
+	// Don’t wait between retries in tests.
+	retryDelay = time.Millisecond
+	maxRetryDelay = time.Millisecond
+}
+
+// roundTripFunc is an http.RoundTripper function.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (fn roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		Name string
+		// Statuses are the statuses of responses in order.
+		// Requests after the last status succeed.
+		Statuses []int
+		Retries  int
+		// Requests is the expected number of requests.
+		Requests int
+		Status   int
+	}{
+		{Name: "ServerError", Statuses: []int{500, 503}, Retries: 2, Requests: 3},
+		{Name: "TooManyRequests", Statuses: []int{429}, Retries: 1, Requests: 2},
+		{Name: "RetriesExceeded", Statuses: []int{502, 502, 502}, Retries: 2, Requests: 3, Status: 502},
+		{Name: "NotFound", Statuses: []int{404}, Retries: 2, Requests: 1, Status: 404},
+		{Name: "Forbidden", Statuses: []int{403}, Retries: 2, Requests: 1, Status: 403},
+		{Name: "NoRetries", Statuses: []int{500}, Requests: 1, Status: 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			var n int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := int(atomic.AddInt32(&n, 1)) - 1
+				if i < len(tt.Statuses) {
+					w.WriteHeader(tt.Statuses[i])
+					return
+				}
+				_, _ = w.Write([]byte("ok"))
+			}))
+			defer srv.Close()
+
+			dl := testFetcher(srv)
+			dl.Retries = tt.Retries
+			var body string
+			err := dl.Get(context.Background(), srv.URL, func(resp *http.Response) error {
+				b, err := ioutil.ReadAll(resp.Body)
+				body = string(b)
+				return err
+			})
+			if got := int(atomic.LoadInt32(&n)); got != tt.Requests {
+				t.Errorf("got %d requests, expected %d", got, tt.Requests)
+			}
+			if tt.Status != 0 {
+				var se *StatusError
+				if !errors.As(err, &se) || se.StatusCode != tt.Status {
+					t.Fatalf("got %v, expected status %d", err, tt.Status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if body != "ok" {
+				t.Fatalf("got %q, expected %q", body, "ok")
+			}
+		})
+	}
+}
+
+func TestErrorNotCached(t *testing.T) {
+	for _, status := range []int{http.StatusForbidden, http.StatusNotFound} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"v1"`)
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte("error page"))
+		}))
+
+		dl := testFetcher(srv)
+		m := modpacker.Mod{
+			Method: modpacker.MethodHTTP,
+			File:   srv.URL + "/mod.jar",
+		}
+		_, err := dl.Open(context.Background(), m)
+		srv.Close()
+
+		var se *StatusError
+		if !errors.As(err, &se) || se.StatusCode != status {
+			t.Errorf("status %d: got %v", status, err)
+			continue
+		}
+		dir, base := httpCachePath(dl.Files, m)
+		for _, ext := range []string{"dat", "sum", "part", "ifrange"} {
+			if _, err := dl.statFile(dir, base, ext); err == nil {
+				t.Errorf("status %d: error response is cached in %s file", status, ext)
+			}
+		}
+	}
+}
+
+func TestContentLength(t *testing.T) {
+	dl := &Fetcher{Files: memfs.New()}
+	dl.Client = &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode:    http.StatusOK,
+				Status:        "200 OK",
+				Header:        make(http.Header),
+				ContentLength: 10,
+				Body:          ioutil.NopCloser(strings.NewReader("0123")),
+				Request:       req,
+			}, nil
+		}),
+	}
+	err := dl.downloadFile(context.Background(), Source{URL: "http://example.com/mod.jar"}, "test", "file")
+	if !errors.Is(err, ErrContentLength) {
+		t.Fatalf("got %v, expected %v", err, ErrContentLength)
+	}
+	for _, ext := range []string{"dat", "part"} {
+		if _, err := dl.statFile("test", "file", ext); err == nil {
+			t.Errorf("truncated file is kept in %s file", ext)
+		}
+	}
+}
+
+// cancelReader cancels the context after the first read.
+type cancelReader struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (r *cancelReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.cancel()
+	return n, err
+}
+
+func TestCancel(t *testing.T) {
+	tests := []struct {
+		Name      string
+		ETag      string
+		Resumable bool
+	}{
+		{Name: "NoValidator"},
+		{Name: "WeakETag", ETag: `W/"v1"`},
+		{Name: "StrongETag", ETag: `"v1"`, Resumable: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.ETag != "" {
+					w.Header().Set("ETag", tt.ETag)
+				}
+				w.Header().Set("Content-Length", "10")
+				_, _ = w.Write([]byte("0123"))
+				w.(http.Flusher).Flush()
+				<-r.Context().Done()
+			}))
+			defer srv.Close()
+
+			// Cancel the download once the first bytes of the
+			// body are read.
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			dl := testFetcher(srv)
+			transport := srv.Client().Transport
+			dl.Client = &http.Client{
+				Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+					resp, err := transport.RoundTrip(req)
+					if err != nil {
+						return nil, err
+					}
+					resp.Body = &cancelReader{ReadCloser: resp.Body, cancel: cancel}
+					return resp, nil
+				}),
+			}
+			err := dl.downloadFile(ctx, Source{URL: srv.URL}, "test", "file")
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("got %v, expected %v", err, context.Canceled)
+			}
+			_, err = dl.statFile("test", "file", "part")
+			if kept := err == nil; kept != tt.Resumable {
+				t.Fatalf("got partial file kept %v, expected %v", kept, tt.Resumable)
+			}
+			if _, err := dl.statData("test", "file"); err == nil {
+				t.Fatal("canceled download is cached")
+			}
+		})
+	}
+}
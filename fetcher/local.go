@@ -0,0 +1,138 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/util"
+
+	"github.com/tie/modpacker/modpacker"
+)
+
+func init() {
+	Register(modpacker.MethodFile, fileMethod{})
+	Register(modpacker.MethodDir, dirMethod{})
+	Register(modpacker.MethodInline, inlineMethod{})
+}
+
+// fileMethod opens local files.
+type fileMethod struct{}
+
+func (fileMethod) Cache(ctx context.Context, dl *Fetcher, m modpacker.Mod) error {
+	return nil
+}
+
+// Sums returns the checksums of the local file, so that changes
+// to the file are detected in the same way as for remote files.
+func (fileMethod) Sums(ctx context.Context, dl *Fetcher, m modpacker.Mod) ([]string, error) {
+	path := filepath.FromSlash(m.File)
+	f, err := osfs.New("").Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Hash(f)
+}
+
+// Open opens the local file, verifying the expected checksums.
+func (fileMethod) Open(ctx context.Context, dl *Fetcher, m modpacker.Mod) (billy.File, error) {
+	path := filepath.FromSlash(m.File)
+	f, err := osfs.New("").Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyFile(f, m.Sums); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// dirMethod opens local directories.
+type dirMethod struct{}
+
+func (dirMethod) Cache(ctx context.Context, dl *Fetcher, m modpacker.Mod) error {
+	return nil
+}
+
+func (dirMethod) Sums(ctx context.Context, dl *Fetcher, m modpacker.Mod) ([]string, error) {
+	return nil, nil
+}
+
+func (dirMethod) Open(ctx context.Context, dl *Fetcher, m modpacker.Mod) (billy.File, error) {
+	return nil, ErrNotFile
+}
+
+func (dirMethod) OpenDir(ctx context.Context, dl *Fetcher, m modpacker.Mod) (billy.Filesystem, error) {
+	path := filepath.FromSlash(m.File)
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, ErrNotDir
+	}
+	return osfs.New(path), nil
+}
+
+func (dirMethod) Vet(m modpacker.Mod) []Problem {
+	if m.Action != modpacker.ActionNone {
+		return []Problem{{
+			Summary: "Unsupported mod action",
+			Detail:  fmt.Sprintf("Action %q cannot be used with \"dir\" method.", m.Action),
+		}}
+	}
+	return nil
+}
+
+// inlineMethod opens the content specified in manifest.
+type inlineMethod struct{}
+
+func (inlineMethod) Cache(ctx context.Context, dl *Fetcher, m modpacker.Mod) error {
+	return nil
+}
+
+func (inlineMethod) Sums(ctx context.Context, dl *Fetcher, m modpacker.Mod) ([]string, error) {
+	return Hash(bytes.NewReader(m.Content))
+}
+
+func (inlineMethod) Vet(m modpacker.Mod) []Problem {
+	var problems []Problem
+	if m.Content == nil {
+		problems = append(problems, Problem{
+			Summary: "Missing mod content",
+			Detail:  "Mods with \"inline\" method require content or content_base64 attribute.",
+		})
+	}
+	if m.File != "" {
+		problems = append(problems, Problem{
+			Summary: "Unused attribute",
+			Detail:  "The file attribute is not used with \"inline\" method.",
+			Warning: true,
+		})
+	}
+	return problems
+}
+
+// Open returns in-memory file with the inline content of mod m.
+func (inlineMethod) Open(ctx context.Context, dl *Fetcher, m modpacker.Mod) (billy.File, error) {
+	sums, err := Hash(bytes.NewReader(m.Content))
+	if err != nil {
+		return nil, err
+	}
+	if err := matchSums(m.Sums, sums); err != nil {
+		return nil, err
+	}
+	fs := memfs.New()
+	const name = "content"
+	if err := util.WriteFile(fs, name, m.Content, 0644); err != nil {
+		return nil, err
+	}
+	return fs.Open(name)
+}
@@ -0,0 +1,168 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+
+	"github.com/tie/modpacker/modpacker"
+)
+
+// DefaultMavenRepository is the URL of Maven Central repository.
+const DefaultMavenRepository = "https://repo.maven.apache.org/maven2"
+
+var (
+	ErrInvalidCoordinates = errors.New("invalid maven coordinates")
+	ErrNoChecksums        = errors.New("maven artifact has no checksum files")
+)
+
+func init() {
+	Register(modpacker.MethodMaven, Remote{
+		CachePath: mavenCachePath,
+		Fetch:     mavenFetch,
+		Problems:  mavenProblems,
+	})
+}
+
+func mavenProblems(m modpacker.Mod) []Problem {
+	if m.Coordinates == "" {
+		return []Problem{{
+			Summary: "Missing Maven artifact",
+			Detail:  "Mods with \"maven\" method require coordinates attribute.",
+		}}
+	}
+	if _, err := ParseCoordinates(m.Coordinates); err != nil {
+		return []Problem{{
+			Summary: "Invalid Maven artifact",
+			Detail:  fmt.Sprintf("Coordinates %q are not in \"group:artifact:version[:classifier][@ext]\" form.", m.Coordinates),
+		}}
+	}
+	return nil
+}
+
+// Artifact is the Maven artifact identified by coordinates.
+type Artifact struct {
+	Group      string
+	Artifact   string
+	Version    string
+	Classifier string
+	// Extension is the file extension, "jar" by default.
+	Extension string
+}
+
+// ParseCoordinates parses artifact coordinates in
+// "group:artifact:version[:classifier][@ext]" form.
+func ParseCoordinates(s string) (Artifact, error) {
+	var a Artifact
+	a.Extension = "jar"
+	if i := strings.LastIndexByte(s, '@'); i != -1 {
+		s, a.Extension = s[:i], s[i+1:]
+	}
+	parts := strings.Split(s, ":")
+	switch len(parts) {
+	case 4:
+		a.Classifier = parts[3]
+		fallthrough
+	case 3:
+		a.Group, a.Artifact, a.Version = parts[0], parts[1], parts[2]
+	default:
+		return a, fmt.Errorf("%w %q", ErrInvalidCoordinates, s)
+	}
+	elems := strings.Split(a.Group, ".")
+	elems = append(elems, a.Artifact, a.Version, a.Extension)
+	if len(parts) == 4 {
+		elems = append(elems, a.Classifier)
+	}
+	for _, elem := range elems {
+		if elem == "" || elem == ".." || strings.ContainsAny(elem, `/\`) {
+			return a, fmt.Errorf("%w %q", ErrInvalidCoordinates, s)
+		}
+	}
+	return a, nil
+}
+
+// Dir returns the directory of the artifact in the repository layout.
+func (a Artifact) Dir() string {
+	group := strings.ReplaceAll(a.Group, ".", "/")
+	return path.Join(group, a.Artifact, a.Version)
+}
+
+// File returns the file name of the artifact.
+func (a Artifact) File() string {
+	name := a.Artifact + "-" + a.Version
+	if a.Classifier != "" {
+		name += "-" + a.Classifier
+	}
+	return name + "." + a.Extension
+}
+
+func mavenURL(repository string, a Artifact) string {
+	if repository == "" {
+		repository = DefaultMavenRepository
+	}
+	repository = strings.TrimSuffix(repository, "/")
+	return repository + "/" + path.Join(a.Dir(), a.File())
+}
+
+func mavenCachePath(fs billy.Basic, m modpacker.Mod) (dir, base string) {
+	// Invalid coordinates are reported on fetch.
+	a, _ := ParseCoordinates(m.Coordinates)
+	return fs.Join("maven", a.Dir()), a.File()
+}
+
+func mavenFetch(ctx context.Context, dl *Fetcher, m modpacker.Mod) (Source, error) {
+	a, err := ParseCoordinates(m.Coordinates)
+	if err != nil {
+		return Source{}, err
+	}
+	u := mavenURL(m.Repository, a)
+	src := Source{URL: u}
+	for _, name := range []string{"sha256", "sha1"} {
+		sum, err := dl.mavenChecksum(ctx, u+"."+name)
+		var se *StatusError
+		if errors.As(err, &se) && se.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return Source{}, err
+		}
+		src.Sums = append(src.Sums, name+":"+sum)
+	}
+	if len(src.Sums) <= 0 {
+		return Source{}, fmt.Errorf("%s: %w", u, ErrNoChecksums)
+	}
+	return src, nil
+}
+
+// mavenChecksum returns the hex-encoded checksum from the checksum file.
+// Some repositories append the file name to the checksum, so only the
+// first field of the file is used.
+func (dl *Fetcher) mavenChecksum(ctx context.Context, rawurl string) (string, error) {
+	var sum string
+	err := dl.Get(ctx, rawurl, func(resp *http.Response) error {
+		// Don’t read responses larger than 1KiB.
+		lr := io.LimitReader(resp.Body, 1024)
+
+		var b strings.Builder
+		if _, err := io.Copy(&b, lr); err != nil {
+			return err
+		}
+		fields := strings.Fields(b.String())
+		if len(fields) <= 0 {
+			return fmt.Errorf("%s: empty checksum file", rawurl)
+		}
+		sum = strings.ToLower(fields[0])
+		if _, err := hex.DecodeString(sum); err != nil {
+			return fmt.Errorf("%s: invalid checksum %q", rawurl, sum)
+		}
+		return nil
+	})
+	return sum, err
+}
@@ -0,0 +1,158 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tie/modpacker/modpacker"
+)
+
+func TestParseCoordinates(t *testing.T) {
+	tests := []struct {
+		Coordinates string
+		Artifact    Artifact
+		Dir         string
+		File        string
+		Err         error
+	}{
+		{
+			Coordinates: "org.example:mod:1.0",
+			Artifact:    Artifact{Group: "org.example", Artifact: "mod", Version: "1.0", Extension: "jar"},
+			Dir:         "org/example/mod/1.0",
+			File:        "mod-1.0.jar",
+		},
+		{
+			Coordinates: "org.example:mod:1.0:universal@zip",
+			Artifact:    Artifact{Group: "org.example", Artifact: "mod", Version: "1.0", Classifier: "universal", Extension: "zip"},
+			Dir:         "org/example/mod/1.0",
+			File:        "mod-1.0-universal.zip",
+		},
+		{Coordinates: "org.example:mod", Err: ErrInvalidCoordinates},
+		{Coordinates: "org.example:mod:1.0:universal:extra", Err: ErrInvalidCoordinates},
+		{Coordinates: "org..example:mod:1.0", Err: ErrInvalidCoordinates},
+		{Coordinates: "org.example:mod:..", Err: ErrInvalidCoordinates},
+		{Coordinates: "org.example:mod:1.0/../..", Err: ErrInvalidCoordinates},
+		{Coordinates: "org.example:mod:1.0:", Err: ErrInvalidCoordinates},
+		{Coordinates: "org.example:mod:1.0@", Err: ErrInvalidCoordinates},
+	}
+	for _, tt := range tests {
+		a, err := ParseCoordinates(tt.Coordinates)
+		if tt.Err != nil {
+			if !errors.Is(err, tt.Err) {
+				t.Errorf("%q: got %v, expected %v", tt.Coordinates, err, tt.Err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.Coordinates, err)
+			continue
+		}
+		if a != tt.Artifact {
+			t.Errorf("%q: got %+v, expected %+v", tt.Coordinates, a, tt.Artifact)
+		}
+		if dir := a.Dir(); dir != tt.Dir {
+			t.Errorf("%q: got dir %q, expected %q", tt.Coordinates, dir, tt.Dir)
+		}
+		if file := a.File(); file != tt.File {
+			t.Errorf("%q: got file %q, expected %q", tt.Coordinates, file, tt.File)
+		}
+	}
+}
+
+func TestMaven(t *testing.T) {
+	const (
+		artifact = "/maven/org/example/mod/1.0/mod-1.0.jar"
+		content  = "mod"
+	)
+	sha1Sum := fmt.Sprintf("%x", sha1.Sum([]byte(content)))
+	sha256Sum := fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+	otherSum := fmt.Sprintf("%x", sha256.Sum256([]byte("other")))
+
+	tests := []struct {
+		Name  string
+		Files map[string]string
+		Err   error
+	}{
+		{
+			Name: "BothChecksums",
+			Files: map[string]string{
+				artifact + ".sha1":   sha1Sum,
+				artifact + ".sha256": sha256Sum,
+			},
+		},
+		{
+			// Some repositories append the file name.
+			Name: "SHA1WithFileName",
+			Files: map[string]string{
+				artifact + ".sha1": sha1Sum + "  mod-1.0.jar\n",
+			},
+		},
+		{
+			Name: "SHA1Mismatch",
+			Files: map[string]string{
+				artifact + ".sha1": otherSum[:40],
+			},
+			Err: ErrSumsMismatch,
+		},
+		{
+			Name: "SHA256Mismatch",
+			Files: map[string]string{
+				artifact + ".sha1":   sha1Sum,
+				artifact + ".sha256": otherSum,
+			},
+			Err: ErrSumsMismatch,
+		},
+		{
+			Name: "NoChecksums",
+			Err:  ErrNoChecksums,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == artifact {
+					_, _ = w.Write([]byte(content))
+					return
+				}
+				data, ok := tt.Files[r.URL.Path]
+				if !ok {
+					http.NotFound(w, r)
+					return
+				}
+				_, _ = w.Write([]byte(data))
+			}))
+			defer srv.Close()
+
+			dl := testFetcher(srv)
+			m := modpacker.Mod{
+				Method:      modpacker.MethodMaven,
+				Repository:  srv.URL + "/maven/",
+				Coordinates: "org.example:mod:1.0",
+			}
+			got, err := readMod(context.Background(), dl, m)
+			if tt.Err != nil {
+				if !errors.Is(err, tt.Err) {
+					t.Fatalf("got %v, expected %v", err, tt.Err)
+				}
+				// Artifacts that do not match must not be cached.
+				dir, base := mavenCachePath(dl.Files, m)
+				if _, err := dl.statData(dir, base); err == nil {
+					t.Fatal("artifact is cached")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != content {
+				t.Fatalf("got %q, expected %q", got, content)
+			}
+		})
+	}
+}
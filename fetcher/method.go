@@ -0,0 +1,180 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-billy/v5"
+
+	"github.com/tie/modpacker/modpacker"
+)
+
+// Method fetches mods with a download method. Methods are registered
+// by name with Register, and Fetcher looks them up by mod method.
+type Method interface {
+	// Cache downloads the mod to the cache unless it is already cached.
+	Cache(ctx context.Context, dl *Fetcher, m modpacker.Mod) error
+	// Sums returns the checksums of the mod file,
+	// or nil if the mod has no checksums.
+	Sums(ctx context.Context, dl *Fetcher, m modpacker.Mod) ([]string, error)
+	// Open opens the mod file, verifying the expected checksums.
+	Open(ctx context.Context, dl *Fetcher, m modpacker.Mod) (billy.File, error)
+}
+
+// DirMethod is implemented by methods that fetch directories.
+type DirMethod interface {
+	Method
+	// OpenDir returns the filesystem rooted at the mod directory.
+	OpenDir(ctx context.Context, dl *Fetcher, m modpacker.Mod) (billy.Filesystem, error)
+}
+
+// AttrMethod is implemented by methods that identify mods with
+// attributes other than the ones known to modpacker, e.g. methods
+// registered outside of this module. The values of the attributes
+// are available in mod’s Attrs and are also used in check blocks.
+type AttrMethod interface {
+	Method
+	// Attributes returns the names of the method’s attributes.
+	Attributes() []string
+}
+
+// VetMethod is implemented by methods that check mods for mistakes
+// before fetching them, e.g. missing or invalid attributes.
+type VetMethod interface {
+	Method
+	// Vet returns the problems with mod m.
+	Vet(m modpacker.Mod) []Problem
+}
+
+// Problem is a mistake in mod reported by VetMethod.
+type Problem struct {
+	// Summary is a short description of the problem.
+	Summary string
+	// Detail is a full sentence that explains the problem.
+	Detail string
+	// Warning is set for problems that do not prevent
+	// the mod from being fetched.
+	Warning bool
+}
+
+var (
+	methodsMu sync.RWMutex
+	methods   = make(map[string]Method)
+)
+
+// Register makes the method available by name. It panics
+// if the method with the same name is already registered.
+func Register(name string, method Method) {
+	methodsMu.Lock()
+	defer methodsMu.Unlock()
+	if method == nil {
+		panic("fetcher: Register method is nil")
+	}
+	if _, dup := methods[name]; dup {
+		panic("fetcher: Register called twice for method " + name)
+	}
+	methods[name] = method
+}
+
+// Lookup returns the method registered with the given name.
+func Lookup(name string) (Method, bool) {
+	methodsMu.RLock()
+	defer methodsMu.RUnlock()
+	method, ok := methods[name]
+	return method, ok
+}
+
+// Methods returns a sorted list of the names of registered methods.
+func Methods() []string {
+	methodsMu.RLock()
+	defer methodsMu.RUnlock()
+	names := make([]string, 0, len(methods))
+	for name := range methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isPathElem reports whether s can be used as a single element
+// of the cache path.
+func isPathElem(s string) bool {
+	return s != "." && s != ".." && !strings.ContainsAny(s, `/\`)
+}
+
+// Source is the location of a remote mod file.
+type Source struct {
+	// URL is the URL to download the file from.
+	URL string
+	// Sums are the checksums published by the mod host, if any.
+	// Downloaded file that does not match them is not cached.
+	Sums []string
+	// Header contains additional request headers,
+	// e.g. for authorization.
+	Header http.Header
+}
+
+// Remote is a method for mods downloaded over HTTP to the cache.
+type Remote struct {
+	// CachePath returns the location of the mod in the cache.
+	// Cache files are stored in dir with base name and extension
+	// for the file type.
+	CachePath func(fs billy.Basic, m modpacker.Mod) (dir, base string)
+	// Fetch returns the source to download the mod from.
+	Fetch func(ctx context.Context, dl *Fetcher, m modpacker.Mod) (Source, error)
+	// Problems, if not nil, returns the problems with mod m.
+	// See VetMethod.
+	Problems func(m modpacker.Mod) []Problem
+}
+
+func (r Remote) Cache(ctx context.Context, dl *Fetcher, m modpacker.Mod) error {
+	dir, base := r.CachePath(dl.Files, m)
+	defer dl.lock(dir, base)()
+	_, err := dl.statData(dir, base)
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	src, err := r.Fetch(ctx, dl, m)
+	if err != nil {
+		return err
+	}
+	return dl.downloadFile(ctx, src, dir, base)
+}
+
+func (r Remote) Sums(ctx context.Context, dl *Fetcher, m modpacker.Mod) ([]string, error) {
+	if err := r.Cache(ctx, dl, m); err != nil {
+		return nil, err
+	}
+	dir, base := r.CachePath(dl.Files, m)
+	defer dl.lock(dir, base)()
+	return dl.cachedSums(dir, base)
+}
+
+func (r Remote) Open(ctx context.Context, dl *Fetcher, m modpacker.Mod) (billy.File, error) {
+	dir, base := r.CachePath(dl.Files, m)
+	defer dl.lock(dir, base)()
+	f, err := dl.getFile(dir, base, m.Sums)
+	if !errors.Is(err, os.ErrNotExist) {
+		return f, err
+	}
+	src, err := r.Fetch(ctx, dl, m)
+	if err != nil {
+		return nil, err
+	}
+	if err := dl.downloadFile(ctx, src, dir, base); err != nil {
+		return nil, err
+	}
+	return dl.getFile(dir, base, m.Sums)
+}
+
+func (r Remote) Vet(m modpacker.Mod) []Problem {
+	if r.Problems == nil {
+		return nil
+	}
+	return r.Problems(m)
+}
@@ -0,0 +1,109 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+
+	"github.com/tie/modpacker/modpacker"
+)
+
+// DefaultModrinthURL is the base URL of Modrinth API.
+const DefaultModrinthURL = "https://api.modrinth.com"
+
+var (
+	ErrNoVersionFiles = errors.New("modrinth version has no files")
+	ErrNoVersionHash  = errors.New("modrinth file has no sha512 hash")
+)
+
+func init() {
+	Register(modpacker.MethodModrinth, Remote{
+		CachePath: modrinthCachePath,
+		Fetch:     modrinthFetch,
+		Problems:  modrinthProblems,
+	})
+}
+
+func modrinthProblems(m modpacker.Mod) []Problem {
+	switch {
+	case m.Project == "" || m.Version == "":
+		return []Problem{{
+			Summary: "Missing Modrinth version",
+			Detail:  "Mods with \"modrinth\" method require project and version attributes.",
+		}}
+	case !isPathElem(m.Project) || !isPathElem(m.Version):
+		return []Problem{{
+			Summary: "Invalid Modrinth version",
+			Detail:  "The project and version attributes must not contain slashes or be \".\" or \"..\".",
+		}}
+	}
+	return nil
+}
+
+// modrinthVersion is the version object returned by Modrinth API.
+type modrinthVersion struct {
+	Files []modrinthFile `json:"files"`
+}
+
+type modrinthFile struct {
+	URL     string            `json:"url"`
+	Primary bool              `json:"primary"`
+	Hashes  map[string]string `json:"hashes"`
+}
+
+func modrinthURL(base, project, version string) string {
+	u := "%s/v2/project/%s/version/%s"
+	base = strings.TrimSuffix(base, "/")
+	return fmt.Sprintf(u, base, url.PathEscape(project), url.PathEscape(version))
+}
+
+func modrinthCachePath(fs billy.Basic, m modpacker.Mod) (dir, base string) {
+	return fs.Join("modrinth", m.Project), m.Version
+}
+
+func modrinthFetch(ctx context.Context, dl *Fetcher, m modpacker.Mod) (Source, error) {
+	base := dl.ModrinthURL
+	if base == "" {
+		base = DefaultModrinthURL
+	}
+	u := modrinthURL(base, m.Project, m.Version)
+	var v modrinthVersion
+	err := dl.Get(ctx, u, func(resp *http.Response) error {
+		// Don’t read responses larger than 1MiB.
+		lr := io.LimitReader(resp.Body, 1024*1024)
+
+		v = modrinthVersion{}
+		return json.NewDecoder(lr).Decode(&v)
+	})
+	if err != nil {
+		return Source{}, err
+	}
+	if len(v.Files) <= 0 {
+		return Source{}, ErrNoVersionFiles
+	}
+	// Versions may have additional files, e.g. sources or
+	// API jars, so we prefer the file marked as primary.
+	f := v.Files[0]
+	for _, file := range v.Files {
+		if file.Primary {
+			f = file
+			break
+		}
+	}
+	sha512 := f.Hashes["sha512"]
+	if sha512 == "" {
+		return Source{}, ErrNoVersionHash
+	}
+	src := Source{
+		URL:  f.URL,
+		Sums: []string{"sha512:" + strings.ToLower(sha512)},
+	}
+	return src, nil
+}
@@ -0,0 +1,120 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tie/modpacker/modpacker"
+)
+
+func sha512Hex(s string) string {
+	return fmt.Sprintf("%x", sha512.Sum512([]byte(s)))
+}
+
+func TestModrinth(t *testing.T) {
+	files := map[string]string{
+		"/files/main.jar":    "main",
+		"/files/sources.jar": "sources",
+	}
+
+	tests := []struct {
+		Name     string
+		Files    []modrinthFile
+		Expected string
+		Err      error
+	}{
+		{
+			Name: "Primary",
+			Files: []modrinthFile{
+				{URL: "/files/sources.jar", Hashes: map[string]string{"sha512": sha512Hex("sources")}},
+				{URL: "/files/main.jar", Primary: true, Hashes: map[string]string{"sha512": sha512Hex("main")}},
+			},
+			Expected: "main",
+		},
+		{
+			Name: "NoPrimary",
+			Files: []modrinthFile{
+				{URL: "/files/main.jar", Hashes: map[string]string{"sha512": sha512Hex("main")}},
+				{URL: "/files/sources.jar", Hashes: map[string]string{"sha512": sha512Hex("sources")}},
+			},
+			Expected: "main",
+		},
+		{
+			Name: "UpperCaseHash",
+			Files: []modrinthFile{
+				{URL: "/files/main.jar", Primary: true, Hashes: map[string]string{"sha512": strings.ToUpper(sha512Hex("main"))}},
+			},
+			Expected: "main",
+		},
+		{
+			Name: "HashMismatch",
+			Files: []modrinthFile{
+				{URL: "/files/main.jar", Primary: true, Hashes: map[string]string{"sha512": sha512Hex("sources")}},
+			},
+			Err: ErrSumsMismatch,
+		},
+		{
+			Name: "NoHash",
+			Files: []modrinthFile{
+				{URL: "/files/main.jar", Primary: true, Hashes: map[string]string{"sha1": "0000"}},
+			},
+			Err: ErrNoVersionHash,
+		},
+		{
+			Name: "NoFiles",
+			Err:  ErrNoVersionFiles,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			var srv *httptest.Server
+			srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/v2/project/mod/version/1.0" {
+					// File URLs are absolute.
+					v := modrinthVersion{Files: make([]modrinthFile, len(tt.Files))}
+					for i, f := range tt.Files {
+						f.URL = srv.URL + f.URL
+						v.Files[i] = f
+					}
+					_ = json.NewEncoder(w).Encode(v)
+					return
+				}
+				content, ok := files[r.URL.Path]
+				if !ok {
+					http.NotFound(w, r)
+					return
+				}
+				_, _ = w.Write([]byte(content))
+			}))
+			defer srv.Close()
+
+			dl := testFetcher(srv)
+			dl.ModrinthURL = srv.URL
+			m := modpacker.Mod{
+				Method:  modpacker.MethodModrinth,
+				Project: "mod",
+				Version: "1.0",
+			}
+			content, err := readMod(context.Background(), dl, m)
+			if tt.Err != nil {
+				if !errors.Is(err, tt.Err) {
+					t.Fatalf("got %v, expected %v", err, tt.Err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if content != tt.Expected {
+				t.Fatalf("got %q, expected %q", content, tt.Expected)
+			}
+		})
+	}
+}
@@ -1,10 +1,10 @@
 package fetcher
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 
@@ -21,6 +21,13 @@ var ErrUnexpectedNode = errors.New("unexpected html node")
 
 var optifineSel = cascadia.MustCompile("#Download > a")
 
+func init() {
+	Register(modpacker.MethodOptifine, Remote{
+		CachePath: optifineCachePath,
+		Fetch:     optifineFetch,
+	})
+}
+
 func optifineURL(file string) string {
 	u := "https://optifine.net/adloadx?f=%s"
 	return fmt.Sprintf(u, url.QueryEscape(file))
@@ -30,35 +37,28 @@ func optifineCachePath(fs billy.Basic, m modpacker.Mod) (dir, base string) {
 	return "optifine", m.File
 }
 
-func optifineFetchURL(c *http.Client, m modpacker.Mod) (string, error) {
+func optifineFetch(ctx context.Context, dl *Fetcher, m modpacker.Mod) (Source, error) {
 	u := optifineURL(m.File)
-	resp, err := c.Get(u)
-	if err != nil {
-		return "", err
-	}
-	r := resp.Body
-	defer func() {
-		err := r.Close()
-		if err != nil {
-			log.Printf("close %q: %+v", u, err)
-		}
-	}()
-
-	// Don’t read HTML pages larger than 1MiB.
-	lr := io.LimitReader(r, 1024*1024)
+	var root *html.Node
+	err := dl.Get(ctx, u, func(resp *http.Response) error {
+		// Don’t read HTML pages larger than 1MiB.
+		lr := io.LimitReader(resp.Body, 1024*1024)
 
-	root, err := html.Parse(lr)
+		n, err := html.Parse(lr)
+		root = n
+		return err
+	})
 	if err != nil {
-		return "", err
+		return Source{}, err
 	}
 	n := optifineSel.MatchFirst(root)
-	if n.Type != html.ElementNode {
+	if n == nil || n.Type != html.ElementNode {
 		err := ErrUnexpectedNode
-		return "", err
+		return Source{}, err
 	}
 	if n.Namespace != "" || n.Data != "a" {
 		err := ErrUnexpectedNode
-		return "", err
+		return Source{}, err
 	}
 	for _, attr := range n.Attr {
 		if attr.Namespace != "" {
@@ -68,7 +68,7 @@ func optifineFetchURL(c *http.Client, m modpacker.Mod) (string, error) {
 			continue
 		}
 		rawurl := fmt.Sprintf("https://optifine.net/%s", attr.Val)
-		return rawurl, nil
+		return Source{URL: rawurl}, nil
 	}
-	return "", ErrUnexpectedNode
+	return Source{}, ErrUnexpectedNode
 }
@@ -0,0 +1,205 @@
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+var ErrContentRange = errors.New("unexpected content range")
+
+// partial is a partially downloaded file in the cache. The file is stored
+// next to the cache entry with .part extension, and the value of If-Range
+// header for resuming the download is stored with .ifrange extension.
+//
+// Downloads are resumed only if the server responded with a strong ETag
+// or Last-Modified header, so that the file is never stitched together
+// from two different versions.
+type partial struct {
+	dl        *Fetcher
+	url       string
+	header    http.Header
+	dir, base string
+
+	// offset is the size of the partial file when the request is sent.
+	offset int64
+	// hashes contain the hashes of the downloaded file.
+	hashes []hash.Hash
+}
+
+func (p *partial) path() string {
+	return p.dl.entryPath(p.dir, p.base, "part")
+}
+
+func (p *partial) validatorPath() string {
+	return p.dl.entryPath(p.dir, p.base, "ifrange")
+}
+
+// prepare adds the range headers to the request if the download
+// can be resumed.
+func (p *partial) prepare(req *http.Request) error {
+	p.offset = 0
+	for k, v := range p.header {
+		req.Header[k] = v
+	}
+	// Responses with transparently decoded content cannot be resumed,
+	// so we disable compression for range requests too.
+	req.Header.Set("Accept-Encoding", "identity")
+
+	fi, err := p.dl.Files.Stat(p.path())
+	if errors.Is(err, os.ErrNotExist) || err == nil && fi.Size() <= 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	validator, err := p.readValidator()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	p.offset = fi.Size()
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", p.offset))
+	req.Header.Set("If-Range", string(validator))
+	return nil
+}
+
+func (p *partial) readValidator() ([]byte, error) {
+	f, err := p.dl.Files.Open(p.validatorPath())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// write writes the response body to the partial file. The response
+// either continues the partial file or replaces it.
+func (p *partial) write(resp *http.Response) error {
+	// Base may contain directories, e.g. for HTTP cache.
+	dir := filepath.Dir(p.dl.Files.Join(p.dir, p.base))
+	if err := p.dl.Files.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	p.hashes = newHashes()
+	ww := make([]io.Writer, len(p.hashes)+1)
+	for i, h := range p.hashes {
+		ww[i] = h
+	}
+
+	var f billy.File
+	if resp.StatusCode == http.StatusPartialContent {
+		start, err := contentRangeStart(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return err
+		}
+		if start != p.offset {
+			return fmt.Errorf("%w: starts at %d, expected %d", ErrContentRange, start, p.offset)
+		}
+		f, err = p.dl.Files.OpenFile(p.path(), os.O_RDWR, 0644)
+		if err != nil {
+			return err
+		}
+		// Hash the data that was already downloaded. Reading
+		// the file also moves the offset to its end.
+		if _, err := io.CopyN(io.MultiWriter(ww[:len(p.hashes)]...), f, p.offset); err != nil {
+			f.Close()
+			return err
+		}
+	} else {
+		// Server ignored the range or the file has changed.
+		p.offset = 0
+		if err := p.saveValidator(resp); err != nil {
+			return err
+		}
+		var err error
+		f, err = p.dl.Files.OpenFile(p.path(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	ww[len(p.hashes)] = f
+
+	n, err := io.Copy(io.MultiWriter(ww...), resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("read %q: %w", p.url, err)
+	}
+	if size := resp.ContentLength; size >= 0 && n != size {
+		return fmt.Errorf("read %q: %w: got %d bytes, expected %d", p.url, ErrContentLength, n, size)
+	}
+	return nil
+}
+
+// saveValidator stores the validator of the response that is later sent
+// in If-Range header. Only strong validators can be used with ranges.
+func (p *partial) saveValidator(resp *http.Response) error {
+	validator := resp.Header.Get("ETag")
+	if strings.HasPrefix(validator, "W/") {
+		validator = ""
+	}
+	if validator == "" {
+		validator = resp.Header.Get("Last-Modified")
+	}
+	if validator == "" || resp.Uncompressed {
+		err := p.dl.Files.Remove(p.validatorPath())
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	return util.WriteFile(p.dl.Files, p.validatorPath(), []byte(validator), 0644)
+}
+
+// resumable reports whether the partial file can be resumed.
+func (p *partial) resumable() bool {
+	_, err := p.dl.Files.Stat(p.validatorPath())
+	return err == nil
+}
+
+// restart reports whether the download failed with err
+// should be restarted without resuming.
+func (p *partial) restart(err error) bool {
+	if errors.Is(err, ErrContentRange) {
+		return true
+	}
+	var se *StatusError
+	return errors.As(err, &se) && se.StatusCode == http.StatusRequestedRangeNotSatisfiable
+}
+
+// remove removes the partial file and its validator.
+func (p *partial) remove() {
+	for _, fpath := range []string{p.path(), p.validatorPath()} {
+		p.dl.removeFile(fpath)
+	}
+}
+
+// contentRangeStart returns the first byte position
+// of "bytes first-last/length" Content-Range header.
+func contentRangeStart(s string) (int64, error) {
+	rest := strings.TrimPrefix(s, "bytes ")
+	i := strings.IndexByte(rest, '-')
+	if rest == s || i == -1 {
+		return 0, fmt.Errorf("%w: %q", ErrContentRange, s)
+	}
+	start, err := strconv.ParseInt(rest[:i], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrContentRange, s)
+	}
+	return start, nil
+}
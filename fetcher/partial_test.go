@@ -0,0 +1,279 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// rangeServer serves content with range requests support.
+type rangeServer struct {
+	mu sync.Mutex
+
+	Content      string
+	ETag         string
+	LastModified string
+	// Truncate, if positive, is the number of bytes sent
+	// before the connection is closed.
+	Truncate int
+	// RangeStatus, if not zero, is the status of responses
+	// to range requests.
+	RangeStatus int
+	// RangeOffset is added to the start of served ranges.
+	RangeOffset int
+
+	// Requests contain the headers of received requests.
+	Requests []http.Header
+}
+
+func (s *rangeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Requests = append(s.Requests, r.Header.Clone())
+
+	validator := s.ETag
+	if validator == "" {
+		validator = s.LastModified
+	}
+	if s.ETag != "" {
+		w.Header().Set("ETag", s.ETag)
+	}
+	if s.LastModified != "" {
+		w.Header().Set("Last-Modified", s.LastModified)
+	}
+
+	body := s.Content
+	status := http.StatusOK
+	rng := r.Header.Get("Range")
+	if rng != "" && r.Header.Get("If-Range") == validator {
+		if s.RangeStatus != 0 {
+			w.WriteHeader(s.RangeStatus)
+			return
+		}
+		start, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+		if err != nil || start > len(s.Content) {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		start += s.RangeOffset
+		body = s.Content[start:]
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(s.Content)-1, len(s.Content)))
+		status = http.StatusPartialContent
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	if s.Truncate > 0 && s.Truncate < len(body) {
+		body = body[:s.Truncate]
+	}
+	_, _ = w.Write([]byte(body))
+}
+
+func (s *rangeServer) update(fn func(s *rangeServer)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s)
+}
+
+func (s *rangeServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.Requests)
+}
+
+func (s *rangeServer) request(i int) http.Header {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Requests[i]
+}
+
+func TestPartial(t *testing.T) {
+	const (
+		dir  = "test"
+		base = "file"
+	)
+	tests := []struct {
+		Name string
+		// Server is the state of the server on the first
+		// download attempt that is interrupted after 4 bytes.
+		Server *rangeServer
+		// Update changes the server before the second attempt.
+		Update func(s *rangeServer)
+		// Resumable is whether the partial file is kept
+		// after the first attempt.
+		Resumable bool
+		// Range and IfRange are the headers of the second request.
+		Range   string
+		IfRange string
+		// Requests is the number of requests on the second attempt.
+		Requests int
+		Expected string
+	}{
+		{
+			Name:      "Resume",
+			Server:    &rangeServer{Content: "0123456789", ETag: `"v1"`},
+			Resumable: true,
+			Range:     "bytes=4-",
+			IfRange:   `"v1"`,
+			Requests:  1,
+			Expected:  "0123456789",
+		},
+		{
+			Name:      "ResumeLastModified",
+			Server:    &rangeServer{Content: "0123456789", LastModified: "Mon, 02 Jan 2006 15:04:05 GMT"},
+			Resumable: true,
+			Range:     "bytes=4-",
+			IfRange:   "Mon, 02 Jan 2006 15:04:05 GMT",
+			Requests:  1,
+			Expected:  "0123456789",
+		},
+		{
+			Name:   "ChangedETag",
+			Server: &rangeServer{Content: "0123456789", ETag: `"v1"`},
+			Update: func(s *rangeServer) {
+				s.Content = "abcdefghijkl"
+				s.ETag = `"v2"`
+			},
+			Resumable: true,
+			Range:     "bytes=4-",
+			IfRange:   `"v1"`,
+			Requests:  1,
+			Expected:  "abcdefghijkl",
+		},
+		{
+			Name:   "RangeNotSatisfiable",
+			Server: &rangeServer{Content: "0123456789", ETag: `"v1"`},
+			Update: func(s *rangeServer) {
+				s.RangeStatus = http.StatusRequestedRangeNotSatisfiable
+			},
+			Resumable: true,
+			Range:     "bytes=4-",
+			IfRange:   `"v1"`,
+			Requests:  2,
+			Expected:  "0123456789",
+		},
+		{
+			Name:   "ContentRangeMismatch",
+			Server: &rangeServer{Content: "0123456789", ETag: `"v1"`},
+			Update: func(s *rangeServer) {
+				s.RangeOffset = 2
+			},
+			Resumable: true,
+			Range:     "bytes=4-",
+			IfRange:   `"v1"`,
+			Requests:  2,
+			Expected:  "0123456789",
+		},
+		{
+			Name:     "WeakETag",
+			Server:   &rangeServer{Content: "0123456789", ETag: `W/"v1"`},
+			Requests: 1,
+			Expected: "0123456789",
+		},
+		{
+			Name:     "NoValidator",
+			Server:   &rangeServer{Content: "0123456789"},
+			Requests: 1,
+			Expected: "0123456789",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			s := tt.Server
+			s.Truncate = 4
+			srv := httptest.NewServer(s)
+			defer srv.Close()
+
+			dl := testFetcher(srv)
+			ctx := context.Background()
+			src := Source{URL: srv.URL}
+
+			if err := dl.downloadFile(ctx, src, dir, base); err == nil {
+				t.Fatal("interrupted download succeeded")
+			}
+			_, err := dl.statFile(dir, base, "part")
+			if resumable := err == nil; resumable != tt.Resumable {
+				t.Fatalf("got resumable %v, expected %v", resumable, tt.Resumable)
+			}
+
+			s.update(func(s *rangeServer) {
+				s.Truncate = 0
+				if tt.Update != nil {
+					tt.Update(s)
+				}
+			})
+			if err := dl.downloadFile(ctx, src, dir, base); err != nil {
+				t.Fatal(err)
+			}
+
+			if n := s.count() - 1; n != tt.Requests {
+				t.Fatalf("got %d requests, expected %d", n, tt.Requests)
+			}
+			h := s.request(1)
+			if got := h.Get("Range"); got != tt.Range {
+				t.Errorf("got Range %q, expected %q", got, tt.Range)
+			}
+			if got := h.Get("If-Range"); got != tt.IfRange {
+				t.Errorf("got If-Range %q, expected %q", got, tt.IfRange)
+			}
+			// Restarted download does not resume.
+			if tt.Requests > 1 {
+				if got := s.request(2).Get("Range"); got != "" {
+					t.Errorf("restarted request has Range %q", got)
+				}
+			}
+
+			f, err := dl.Files.Open(dl.entryPath(dir, base, "dat"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			b, err := ioutil.ReadAll(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.Expected {
+				t.Fatalf("got %q, expected %q", b, tt.Expected)
+			}
+			sums, err := dl.readSums(dir, base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, _ := Hash(strings.NewReader(tt.Expected))
+			if err := matchSums(expected, sums); err != nil {
+				t.Fatalf("sums %q: %v", sums, err)
+			}
+			for _, ext := range []string{"part", "ifrange"} {
+				if _, err := dl.statFile(dir, base, ext); err == nil {
+					t.Errorf("%s file was not removed", ext)
+				}
+			}
+		})
+	}
+}
+
+func TestContentRangeStart(t *testing.T) {
+	tests := []struct {
+		Header string
+		Start  int64
+		Err    bool
+	}{
+		{"bytes 0-9/10", 0, false},
+		{"bytes 4-9/10", 4, false},
+		{"bytes 4-9/*", 4, false},
+		{"bytes */10", 0, true},
+		{"4-9/10", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		start, err := contentRangeStart(tt.Header)
+		if (err != nil) != tt.Err || start != tt.Start {
+			t.Errorf("contentRangeStart(%q) = %d, %v", tt.Header, start, err)
+		}
+	}
+}
@@ -5,11 +5,27 @@ const (
 	MethodHTTP     = "http"
 	MethodCurse    = "curse"
 	MethodOptifine = "optifine"
+	MethodDir      = "dir"
+	MethodInline   = "inline"
+	MethodModrinth = "modrinth"
+	MethodGitHub   = "github"
+	MethodMaven    = "maven"
+	MethodGit      = "git"
 )
 
 const (
-	ActionNone  = ""
-	ActionUnzip = "unzip"
+	ActionNone     = ""
+	ActionUnzip    = "unzip"
+	ActionUntar    = "untar"
+	ActionExtract  = "extract"
+	ActionTemplate = "template"
+	ActionPatch    = "patch"
+)
+
+const (
+	SideBoth   = "both"
+	SideClient = "client"
+	SideServer = "server"
 )
 
 type Mod struct {
@@ -17,21 +33,125 @@ type Mod struct {
 	Path string
 
 	// Method is the method used for downloading the mod.
-	// Possible values: "", "curse", "optifine", "http".
+	// Possible values: "", "curse", "optifine", "http", "dir", "inline",
+	// "modrinth", "github", "maven", "git".
 	Method string
 
 	// Action is the additional action to perform
 	// on the downloaded file (e.g. "unzip" world save).
+	// Possible values: "", "unzip", "untar", "extract", "template", "patch".
 	Action string
 
-	// File specifies the OptiFine file name.
+	// File specifies the OptiFine file name, the URL for "http"
+	// method, or the local path for "" and "dir" methods. For "inline"
+	// method, it is the mod path that identifies the content in checks.
 	File string
 
+	// Content is the file content for "inline" method.
+	Content []byte
+
 	// ProjectID specifies the project ID on CurseForge.
 	ProjectID int
 	// FileID specifies the file ID of the CurseForge project.
 	FileID int
 
+	// Project specifies the Modrinth project ID or slug.
+	Project string
+	// Version specifies the version ID or version number
+	// of the Modrinth project.
+	Version string
+
+	// Repo specifies the GitHub repository in "owner/repo" form.
+	Repo string
+	// Tag specifies the tag of the GitHub release.
+	Tag string
+	// Asset specifies the file name of the release asset.
+	Asset string
+
+	// Repository specifies the Maven repository URL.
+	Repository string
+	// Coordinates specify the Maven artifact in
+	// "group:artifact:version[:classifier][@ext]" form.
+	Coordinates string
+
+	// URL specifies the git repository URL.
+	URL string
+	// Ref specifies the branch or tag of the git repository.
+	Ref string
+	// Commit specifies the git commit hash. If set,
+	// it is used instead of Ref.
+	Commit string
+	// RepoPath is the path of the file or directory
+	// in the git repository.
+	RepoPath string
+
+	// Side is the side the mod is required on.
+	// Possible values: "both", "client", "server".
+	Side string
+
+	// Include and Exclude are file name patterns that select files
+	// from directory or archive. See builder.Filter for the pattern
+	// syntax.
+	Include []string
+	Exclude []string
+
+	// Strip is the number of leading path elements to remove
+	// from names of the archive members.
+	Strip int
+	// Subdir is the directory in archive to extract.
+	Subdir string
+	// Member is the archive member to extract with "extract" action.
+	Member string
+
+	// Patches is a list of local unified diff files. The diffs are
+	// applied to the file with "patch" action, or to the extracted
+	// files with matching names when extracting archives.
+	Patches []string
+
+	// Optional marks the mod as optional. Optional mods are added only
+	// by builders that support optional files, unless the mod is
+	// explicitly selected.
+	Optional bool
+	// Groups is a list of groups that allow selecting
+	// or excluding the mod.
+	Groups []string
+
 	// Sums is a list of expected file checksums.
 	Sums []string
+
+	// Attrs contains attributes for methods registered outside
+	// of modpacker. See fetcher.AttrMethod.
+	Attrs map[string]string
+}
+
+// OnSide reports whether the mod should be installed on the given side.
+func (m *Mod) OnSide(side string) bool {
+	if side == SideBoth || m.Side == SideBoth {
+		return true
+	}
+	return m.Side == side
+}
+
+// InGroups reports whether the mod belongs to any of the given groups.
+func (m *Mod) InGroups(groups []string) bool {
+	for _, g := range groups {
+		for _, mg := range m.Groups {
+			if g == mg {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type Modpack struct {
+	Name        string
+	Version     string
+	Author      string
+	Description string
+
+	// Minecraft is the Minecraft version (e.g. "1.12.2").
+	Minecraft string
+	// Loader is the mod loader ID (e.g. "forge-14.23.5.2847").
+	Loader string
 }
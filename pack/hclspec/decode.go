@@ -0,0 +1,334 @@
+package hclspec
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// header contains blocks that define the evaluation context
+// for the rest of the manifest.
+type header struct {
+	Variables []Variable `hcl:"variable,block"`
+	Locals    []Locals   `hcl:"locals,block"`
+	Remain    hcl.Body   `hcl:",remain"`
+}
+
+// DecodeManifest decodes the manifest from body. Input variables are set
+// from vars, or from their default values if vars does not contain them.
+//
+// Unlike gohcl.DecodeBody, it also records source ranges of the decoded
+// blocks for diagnostics.
+func DecodeManifest(body hcl.Body, vars map[string]cty.Value) (Manifest, hcl.Diagnostics) {
+	var m Manifest
+
+	// Variable defaults and locals can use functions,
+	// but not other variables.
+	ctx := &hcl.EvalContext{
+		Functions: Functions(),
+	}
+
+	var h header
+	diags := gohcl.DecodeBody(body, ctx, &h)
+	if diags.HasErrors() {
+		return m, diags
+	}
+	ranges := blockRanges(body, &h)
+	for i, r := range ranges["variable"] {
+		if i >= len(h.Variables) {
+			break
+		}
+		h.Variables[i].DeclRange = r
+	}
+
+	varVals, varDiags := variableValues(h.Variables, vars)
+	diags = append(diags, varDiags...)
+	ctx.Variables = map[string]cty.Value{
+		"var":   cty.ObjectVal(varVals),
+		"local": cty.EmptyObjectVal,
+	}
+
+	localVals, localDiags := localValues(h.Locals, ctx)
+	diags = append(diags, localDiags...)
+	ctx.Variables["local"] = cty.ObjectVal(localVals)
+
+	if diags.HasErrors() {
+		return m, diags
+	}
+
+	decodeDiags := gohcl.DecodeBody(h.Remain, ctx, &m)
+	diags = append(diags, decodeDiags...)
+	m.Variables = varVals
+	m.Locals = localVals
+
+	for i := range m.Mods {
+		attrs, attrDiags := decodeAttrs(m.Mods[i].Extra, ctx)
+		diags = append(diags, attrDiags...)
+		m.Mods[i].Attrs = attrs
+	}
+	for i := range m.Checks {
+		attrs, attrDiags := decodeAttrs(m.Checks[i].Extra, ctx)
+		diags = append(diags, attrDiags...)
+		m.Checks[i].Attrs = attrs
+	}
+
+	// Diagnostics were already reported above.
+	ranges = blockRanges(h.Remain, &m)
+	for i, r := range ranges["import"] {
+		if i >= len(m.Imports) {
+			break
+		}
+		m.Imports[i].DeclRange = r
+	}
+	for _, r := range ranges["modpack"] {
+		if m.Modpack == nil {
+			break
+		}
+		m.Modpack.DeclRange = r
+	}
+	for i, r := range ranges["mod"] {
+		if i >= len(m.Mods) {
+			break
+		}
+		m.Mods[i].DeclRange = r
+	}
+	for i, r := range ranges["remove"] {
+		if i >= len(m.Removes) {
+			break
+		}
+		m.Removes[i].DeclRange = r
+	}
+	for i, r := range ranges["check"] {
+		if i >= len(m.Checks) {
+			break
+		}
+		m.Checks[i].DeclRange = r
+	}
+
+	return m, diags
+}
+
+// decodeAttrs decodes values of extra attributes.
+func decodeAttrs(attrs hcl.Attributes, ctx *hcl.EvalContext) (map[string]string, hcl.Diagnostics) {
+	if len(attrs) <= 0 {
+		return nil, nil
+	}
+	var diags hcl.Diagnostics
+	vals := make(map[string]string, len(attrs))
+	for name, attr := range attrs {
+		var s string
+		diags = append(diags, gohcl.DecodeExpression(attr.Expr, ctx, &s)...)
+		vals[name] = s
+	}
+	return vals, diags
+}
+
+// ModContent is the content of mod block returned by CheckSchema.
+type ModContent struct {
+	// Attributes are the attributes in mod schema.
+	Attributes hcl.Attributes
+	// Extra are the attributes that are not in the schema.
+	Extra hcl.Attributes
+}
+
+// CheckSchema reports blocks and attributes of the manifest body that
+// do not match the manifest schema. Unlike DecodeManifest, it does not
+// evaluate expressions, so that manifests can be checked without input
+// variable values. It returns the contents of mod blocks.
+func CheckSchema(body hcl.Body) ([]ModContent, hcl.Diagnostics) {
+	headerSchema, _ := gohcl.ImpliedBodySchema(&header{})
+	schema, _ := gohcl.ImpliedBodySchema(&Manifest{})
+	schema.Blocks = append(schema.Blocks, headerSchema.Blocks...)
+
+	content, diags := body.Content(schema)
+	var mods []ModContent
+	for _, b := range content.Blocks {
+		var val interface{}
+		switch b.Type {
+		case "variable":
+			val = &Variable{}
+		case "import":
+			val = &Import{}
+		case "modpack":
+			val = &Modpack{}
+		case "mod":
+			val = &Mod{}
+		case "remove":
+			val = &Remove{}
+		case "check":
+			val = &Check{}
+		default:
+			// Locals may declare any attributes.
+			continue
+		}
+		blockSchema, partial := gohcl.ImpliedBodySchema(val)
+		if !partial {
+			_, blockDiags := b.Body.Content(blockSchema)
+			diags = append(diags, blockDiags...)
+			continue
+		}
+		blockContent, remain, blockDiags := b.Body.PartialContent(blockSchema)
+		diags = append(diags, blockDiags...)
+		extra, extraDiags := remain.JustAttributes()
+		diags = append(diags, extraDiags...)
+		if b.Type == "mod" {
+			mods = append(mods, ModContent{
+				Attributes: blockContent.Attributes,
+				Extra:      extra,
+			})
+		}
+	}
+	return mods, diags
+}
+
+// blockRanges returns definition ranges of body blocks by block type.
+//
+// gohcl does not expose block ranges, but it decodes blocks in the order
+// they appear in the body, so the ranges can be matched with decoded
+// values by index.
+func blockRanges(body hcl.Body, val interface{}) map[string][]hcl.Range {
+	schema, _ := gohcl.ImpliedBodySchema(val)
+	content, _, _ := body.PartialContent(schema)
+	if content == nil {
+		return nil
+	}
+	ranges := make(map[string][]hcl.Range)
+	for _, b := range content.Blocks {
+		ranges[b.Type] = append(ranges[b.Type], b.DefRange)
+	}
+	return ranges
+}
+
+func variableValues(vs []Variable, vars map[string]cty.Value) (map[string]cty.Value, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+
+	vals := make(map[string]cty.Value, len(vs))
+	for _, v := range vs {
+		if _, ok := vals[v.Name]; ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate variable",
+				Detail:   fmt.Sprintf("Variable %q was already declared in this manifest.", v.Name),
+				Subject:  v.DeclRange.Ptr(),
+			})
+			continue
+		}
+
+		val, ok := vars[v.Name]
+		if !ok {
+			if v.Default.IsNull() {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Missing variable value",
+					Detail:   fmt.Sprintf("Variable %q has no default value and no value was given.", v.Name),
+					Subject:  v.DeclRange.Ptr(),
+				})
+				val = cty.DynamicVal
+			} else {
+				val = v.Default
+			}
+		} else if !v.Default.IsNull() {
+			// Values from command line are strings, so we
+			// convert them to the type of the default value.
+			cv, err := convert.Convert(val, v.Default.Type())
+			if err != nil {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid value for variable",
+					Detail:   fmt.Sprintf("Variable %q expects %s value: %s.", v.Name, v.Default.Type().FriendlyName(), err),
+					Subject:  v.DeclRange.Ptr(),
+				})
+				cv = cty.DynamicVal
+			}
+			val = cv
+		}
+		vals[v.Name] = val
+	}
+	return vals, diags
+}
+
+func localValues(ls []Locals, ctx *hcl.EvalContext) (map[string]cty.Value, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+
+	var pending []*hcl.Attribute
+	declared := make(map[string]*hcl.Attribute)
+	for _, l := range ls {
+		for _, attr := range l.Attrs {
+			if prev, ok := declared[attr.Name]; ok {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Duplicate local value",
+					Detail:   fmt.Sprintf("Local value %q was already declared at %s.", attr.Name, prev.NameRange),
+					Subject:  attr.NameRange.Ptr(),
+				})
+				continue
+			}
+			declared[attr.Name] = attr
+			pending = append(pending, attr)
+		}
+	}
+	// Attributes are stored in map, so we sort them
+	// to get deterministic diagnostics.
+	sort.Slice(pending, func(i, j int) bool {
+		a, b := pending[i].Range, pending[j].Range
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Start.Byte < b.Start.Byte
+	})
+
+	// Locals may refer to each other in any order, so we evaluate
+	// them until no more values can be resolved.
+	vals := make(map[string]cty.Value, len(pending))
+	for len(pending) > 0 {
+		var next []*hcl.Attribute
+		for _, attr := range pending {
+			if !localReady(attr, declared, vals) {
+				next = append(next, attr)
+				continue
+			}
+			val, valDiags := attr.Expr.Value(ctx)
+			diags = append(diags, valDiags...)
+			vals[attr.Name] = val
+			ctx.Variables["local"] = cty.ObjectVal(vals)
+		}
+		if len(next) == len(pending) {
+			for _, attr := range next {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Local value cycle",
+					Detail:   fmt.Sprintf("Local value %q depends on itself.", attr.Name),
+					Subject:  attr.Expr.Range().Ptr(),
+				})
+			}
+			break
+		}
+		pending = next
+	}
+	return vals, diags
+}
+
+// localReady reports whether all locals referenced by attr are evaluated.
+// References to undeclared locals are reported on evaluation.
+func localReady(attr *hcl.Attribute, declared map[string]*hcl.Attribute, vals map[string]cty.Value) bool {
+	for _, t := range attr.Expr.Variables() {
+		if t.RootName() != "local" || len(t) < 2 {
+			continue
+		}
+		step, ok := t[1].(hcl.TraverseAttr)
+		if !ok {
+			continue
+		}
+		if _, ok := declared[step.Name]; !ok {
+			continue
+		}
+		if _, ok := vals[step.Name]; !ok {
+			return false
+		}
+	}
+	return true
+}
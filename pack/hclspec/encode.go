@@ -0,0 +1,258 @@
+package hclspec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/gocty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// item is either an attribute or a block of encoded body.
+type item struct {
+	Name string
+
+	// Value is the attribute value.
+	Value cty.Value
+
+	// Blocks contains blocks of Name type. Blocks are
+	// grouped by type since JSON syntax requires that.
+	Blocks []block
+}
+
+type block struct {
+	Labels []string
+	Body   []item
+}
+
+// EncodeHCL encodes the manifest using native syntax. Unlike
+// gohcl.EncodeIntoBody, it omits optional attributes with zero values.
+func EncodeHCL(m *Manifest) []byte {
+	f := hclwrite.NewEmptyFile()
+	writeBody(f.Body(), encodeBody(reflect.ValueOf(m).Elem()))
+	return f.Bytes()
+}
+
+// EncodeJSON encodes the manifest using JSON syntax. Blocks are encoded
+// as arrays to preserve their order.
+func EncodeJSON(m *Manifest) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, encodeBody(reflect.ValueOf(m).Elem())); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+var valueType = reflect.TypeOf(cty.Value{})
+
+// encodeBody encodes struct value v using the same field tags as gohcl.
+// It panics if the value cannot be encoded.
+func encodeBody(v reflect.Value) []item {
+	var items []item
+
+	ty := v.Type()
+	for i := 0; i < ty.NumField(); i++ {
+		field := ty.Field(i)
+		tag := field.Tag.Get("hcl")
+		if tag == "" {
+			continue
+		}
+		name, kind := tag, "attr"
+		if comma := strings.IndexByte(tag, ','); comma != -1 {
+			name, kind = tag[:comma], tag[comma+1:]
+		}
+
+		fv := v.Field(i)
+		switch kind {
+		case "attr", "optional":
+			if kind == "optional" && fv.IsZero() {
+				continue
+			}
+			val := encodeValue(fv)
+			if val.IsNull() {
+				continue
+			}
+			items = append(items, item{
+				Name:  name,
+				Value: val,
+			})
+		case "remain":
+			// Values of attributes that are not in the schema
+			// are decoded to Attrs.
+			items = append(items, encodeAttrs(v.FieldByName("Attrs"))...)
+		case "block":
+			var blocks []block
+			switch fv.Kind() {
+			case reflect.Slice:
+				for j := 0; j < fv.Len(); j++ {
+					blocks = append(blocks, encodeBlock(fv.Index(j)))
+				}
+			case reflect.Ptr:
+				if fv.IsNil() {
+					continue
+				}
+				blocks = append(blocks, encodeBlock(fv.Elem()))
+			default:
+				blocks = append(blocks, encodeBlock(fv))
+			}
+			if len(blocks) <= 0 {
+				continue
+			}
+			items = append(items, item{
+				Name:   name,
+				Blocks: blocks,
+			})
+		}
+	}
+	return items
+}
+
+// encodeAttrs encodes attributes from map[string]string value in
+// sorted order. Values of other types are ignored.
+func encodeAttrs(v reflect.Value) []item {
+	if !v.IsValid() {
+		return nil
+	}
+	attrs, ok := v.Interface().(map[string]string)
+	if !ok {
+		return nil
+	}
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	items := make([]item, len(names))
+	for i, name := range names {
+		items[i] = item{
+			Name:  name,
+			Value: cty.StringVal(attrs[name]),
+		}
+	}
+	return items
+}
+
+func encodeBlock(v reflect.Value) block {
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	var labels []string
+	ty := v.Type()
+	for i := 0; i < ty.NumField(); i++ {
+		tag := ty.Field(i).Tag.Get("hcl")
+		if strings.HasSuffix(tag, ",label") {
+			labels = append(labels, v.Field(i).String())
+		}
+	}
+	return block{
+		Labels: labels,
+		Body:   encodeBody(v),
+	}
+}
+
+func encodeValue(v reflect.Value) cty.Value {
+	if v.Type() == valueType {
+		return v.Interface().(cty.Value)
+	}
+	ty, err := gocty.ImpliedType(v.Interface())
+	if err != nil {
+		panic(fmt.Sprintf("cannot encode %s as HCL expression: %s", v.Type(), err))
+	}
+	val, err := gocty.ToCtyValue(v.Interface(), ty)
+	if err != nil {
+		panic(fmt.Sprintf("failed to encode %s as %#v: %s", v.Type(), ty, err))
+	}
+	return val
+}
+
+func writeBody(body *hclwrite.Body, items []item) {
+	prevWasBlock := false
+	for _, it := range items {
+		if it.Blocks == nil {
+			body.SetAttributeValue(it.Name, it.Value)
+			continue
+		}
+		for _, b := range it.Blocks {
+			if prevWasBlock || len(body.Attributes()) > 0 {
+				body.AppendNewline()
+			}
+			prevWasBlock = true
+			nb := body.AppendNewBlock(it.Name, b.Labels)
+			writeBody(nb.Body(), b.Body)
+		}
+	}
+}
+
+func writeJSON(buf *bytes.Buffer, items []item) error {
+	buf.WriteByte('{')
+	for i, it := range items {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSONString(buf, it.Name)
+		buf.WriteByte(':')
+
+		if it.Blocks == nil {
+			// Strings in JSON syntax are templates,
+			// so we escape template sequences.
+			val, err := cty.Transform(it.Value, escapeTemplate)
+			if err != nil {
+				return err
+			}
+			data, err := ctyjson.Marshal(val, val.Type())
+			if err != nil {
+				return err
+			}
+			buf.Write(data)
+			continue
+		}
+
+		buf.WriteByte('[')
+		for j, b := range it.Blocks {
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+			// Labels are encoded as nested objects.
+			for _, l := range b.Labels {
+				buf.WriteByte('{')
+				writeJSONString(buf, l)
+				buf.WriteByte(':')
+			}
+			if err := writeJSON(buf, b.Body); err != nil {
+				return err
+			}
+			for range b.Labels {
+				buf.WriteByte('}')
+			}
+		}
+		buf.WriteByte(']')
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	// Marshaling a string never fails.
+	data, _ := json.Marshal(s)
+	buf.Write(data)
+}
+
+var templateEscaper = strings.NewReplacer("${", "$${", "%{", "%%{")
+
+func escapeTemplate(_ cty.Path, v cty.Value) (cty.Value, error) {
+	if v.IsNull() || !v.IsKnown() || v.Type() != cty.String {
+		return v, nil
+	}
+	return cty.StringVal(templateEscaper.Replace(v.AsString())), nil
+}
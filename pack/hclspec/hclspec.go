@@ -1,23 +1,122 @@
 package hclspec
 
+import (
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
 type Manifest struct {
-	Mods   []Mod   `hcl:"mod,block"`
-	Checks []Check `hcl:"check,block"`
+	Imports []Import `hcl:"import,block"`
+	Modpack *Modpack `hcl:"modpack,block"`
+	Mods    []Mod    `hcl:"mod,block"`
+	Removes []Remove `hcl:"remove,block"`
+	Checks  []Check  `hcl:"check,block"`
+
+	// Variables and Locals contain values of input variables
+	// and local values used to decode the manifest.
+	Variables map[string]cty.Value
+	Locals    map[string]cty.Value
+}
+
+type Variable struct {
+	Name        string    `hcl:"name,label"`
+	Description string    `hcl:"description,optional"`
+	Default     cty.Value `hcl:"default,optional"`
+
+	DeclRange hcl.Range
+}
+
+type Locals struct {
+	Attrs hcl.Attributes `hcl:",remain"`
+}
+
+type Import struct {
+	Path string `hcl:"path,label"`
+
+	DeclRange hcl.Range
+}
+
+type Modpack struct {
+	Name        string `hcl:"name,optional"`
+	Version     string `hcl:"version,optional"`
+	Author      string `hcl:"author,optional"`
+	Description string `hcl:"description,optional"`
+	Minecraft   string `hcl:"minecraft,optional"`
+	Loader      string `hcl:"loader,optional"`
+
+	DeclRange hcl.Range
 }
 
 type Mod struct {
-	Path      string `hcl:"path,label"`
-	Action    string `hcl:"action,optional"`
-	Method    string `hcl:"method,optional"`
-	File      string `hcl:"file,optional"`
-	ProjectID int    `hcl:"projectID,optional"`
-	FileID    int    `hcl:"fileID,optional"`
+	Path        string   `hcl:"path,label"`
+	Action      string   `hcl:"action,optional"`
+	Method      string   `hcl:"method,optional"`
+	File        string   `hcl:"file,optional"`
+	ProjectID   int      `hcl:"projectID,optional"`
+	FileID      int      `hcl:"fileID,optional"`
+	Project     string   `hcl:"project,optional"`
+	Version     string   `hcl:"version,optional"`
+	Repo        string   `hcl:"repo,optional"`
+	Tag         string   `hcl:"tag,optional"`
+	Asset       string   `hcl:"asset,optional"`
+	Repository  string   `hcl:"repository,optional"`
+	Coordinates string   `hcl:"coordinates,optional"`
+	URL         string   `hcl:"url,optional"`
+	Ref         string   `hcl:"ref,optional"`
+	Commit      string   `hcl:"commit,optional"`
+	RepoPath    string   `hcl:"path,optional"`
+	Side        string   `hcl:"side,optional"`
+	Optional    bool     `hcl:"optional,optional"`
+	Groups      []string `hcl:"groups,optional"`
+	Include     []string `hcl:"include,optional"`
+	Exclude     []string `hcl:"exclude,optional"`
+	Strip       int      `hcl:"strip,optional"`
+	Subdir      string   `hcl:"subdir,optional"`
+	Member      string   `hcl:"member,optional"`
+	Patches     []string `hcl:"patches,optional"`
+
+	// Content and ContentBase64 specify the file content
+	// for "inline" method.
+	Content       *string `hcl:"content,optional"`
+	ContentBase64 *string `hcl:"content_base64,optional"`
+
+	// Extra contains attributes that are not in the schema, e.g. for
+	// methods registered outside of modpacker. Attrs contains their
+	// values.
+	Extra hcl.Attributes `hcl:",remain"`
+	Attrs map[string]string
+
+	DeclRange hcl.Range
+}
+
+type Remove struct {
+	Path string `hcl:"path,label"`
+
+	DeclRange hcl.Range
 }
 
 type Check struct {
-	Method    string   `hcl:"method,attr"`
-	File      string   `hcl:"file,optional"`
-	ProjectID int      `hcl:"projectID,optional"`
-	FileID    int      `hcl:"fileID,optional"`
-	Sums      []string `hcl:"sums,attr"`
+	Method      string `hcl:"method,attr"`
+	File        string `hcl:"file,optional"`
+	ProjectID   int    `hcl:"projectID,optional"`
+	FileID      int    `hcl:"fileID,optional"`
+	Project     string `hcl:"project,optional"`
+	Version     string `hcl:"version,optional"`
+	Repo        string `hcl:"repo,optional"`
+	Tag         string `hcl:"tag,optional"`
+	Asset       string `hcl:"asset,optional"`
+	Repository  string `hcl:"repository,optional"`
+	Coordinates string `hcl:"coordinates,optional"`
+	URL         string `hcl:"url,optional"`
+	Ref         string `hcl:"ref,optional"`
+	Commit      string `hcl:"commit,optional"`
+	RepoPath    string `hcl:"path,optional"`
+
+	// Extra and Attrs are the same as for Mod.
+	Extra hcl.Attributes `hcl:",remain"`
+	Attrs map[string]string
+
+	Sums []string `hcl:"sums,attr"`
+
+	DeclRange hcl.Range
 }
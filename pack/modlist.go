@@ -1,67 +1,336 @@
 package pack
 
 import (
+	"encoding/base64"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+
+	"github.com/tie/modpacker/fetcher"
 	"github.com/tie/modpacker/modpacker"
 	"github.com/tie/modpacker/pack/hclspec"
 )
 
-type modID struct {
-	Method    string
-	File      string
-	ProjectID int
-	FileID    int
+// ModID identifies the source of a mod. Mods with the same ID are
+// fetched from the same source and share check blocks.
+type ModID struct {
+	Method      string
+	File        string
+	ProjectID   int
+	FileID      int
+	Project     string
+	Version     string
+	Repo        string
+	Tag         string
+	Asset       string
+	Repository  string
+	Coordinates string
+	URL         string
+	Ref         string
+	Commit      string
+	RepoPath    string
+	Attrs       string
 }
 
-func ModList(ms []hclspec.Manifest) []modpacker.Mod {
-	n := 0
-	for _, m := range ms {
-		n += len(m.Mods)
+// ModIDOf returns the ID of the mod source.
+func ModIDOf(m modpacker.Mod) ModID {
+	return ModID{
+		Method:      m.Method,
+		File:        m.File,
+		ProjectID:   m.ProjectID,
+		FileID:      m.FileID,
+		Project:     m.Project,
+		Version:     m.Version,
+		Repo:        m.Repo,
+		Tag:         m.Tag,
+		Asset:       m.Asset,
+		Repository:  m.Repository,
+		Coordinates: m.Coordinates,
+		URL:         m.URL,
+		Ref:         m.Ref,
+		Commit:      m.Commit,
+		RepoPath:    m.RepoPath,
+		Attrs:       attrsKey(m.Attrs),
 	}
+}
 
-	if len(ms) <= 0 || n <= 0 {
-		return nil
+// attrsKey returns a comparable representation of extra attributes.
+func attrsKey(attrs map[string]string) string {
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%q=%q;", name, attrs[name])
+	}
+	return b.String()
+}
+
+// toMod converts the mod spec. Content and checksums are not set.
+func toMod(mod hclspec.Mod) modpacker.Mod {
+	method, file := sourceOf(mod)
+	m := modpacker.Mod{
+		Path:        mod.Path,
+		Method:      method,
+		Action:      mod.Action,
+		File:        file,
+		ProjectID:   mod.ProjectID,
+		FileID:      mod.FileID,
+		Project:     mod.Project,
+		Version:     mod.Version,
+		Repo:        mod.Repo,
+		Tag:         mod.Tag,
+		Asset:       mod.Asset,
+		Repository:  mod.Repository,
+		Coordinates: mod.Coordinates,
+		URL:         mod.URL,
+		Ref:         mod.Ref,
+		Commit:      mod.Commit,
+		RepoPath:    mod.RepoPath,
+		Side:        mod.Side,
+		Optional:    mod.Optional,
+		Groups:      mod.Groups,
+		Include:     mod.Include,
+		Exclude:     mod.Exclude,
+		Strip:       mod.Strip,
+		Subdir:      mod.Subdir,
+		Member:      mod.Member,
+		Patches:     mod.Patches,
+		Attrs:       mod.Attrs,
+	}
+	if mod.Side == "" {
+		m.Side = modpacker.SideBoth
+	}
+	return m
+}
+
+// sourceOf returns the method and file of the mod. Mods with inline
+// content default to "inline" method and are identified by their path.
+func sourceOf(mod hclspec.Mod) (method, file string) {
+	method, file = mod.Method, mod.File
+	if method == modpacker.MethodFile && hasContent(mod) {
+		method = modpacker.MethodInline
+	}
+	if method == modpacker.MethodInline {
+		file = path.Clean(mod.Path)
+	}
+	return method, file
+}
+
+func hasContent(mod hclspec.Mod) bool {
+	return mod.Content != nil || mod.ContentBase64 != nil
+}
+
+func checkID(check hclspec.Check) ModID {
+	return ModID{
+		Method:      check.Method,
+		File:        check.File,
+		ProjectID:   check.ProjectID,
+		FileID:      check.FileID,
+		Project:     check.Project,
+		Version:     check.Version,
+		Repo:        check.Repo,
+		Tag:         check.Tag,
+		Asset:       check.Asset,
+		Repository:  check.Repository,
+		Coordinates: check.Coordinates,
+		URL:         check.URL,
+		Ref:         check.Ref,
+		Commit:      check.Commit,
+		RepoPath:    check.RepoPath,
+		Attrs:       attrsKey(check.Attrs),
 	}
+}
 
-	mods := make([]modpacker.Mod, n)
-	refs := make(map[modID]*modpacker.Mod, n)
+// ModList merges mods from manifests. Manifests are applied in order,
+// so that a mod block in later manifest replaces the mod with the same
+// path from earlier manifests, and a remove block drops it.
+func ModList(ms []hclspec.Manifest) ([]modpacker.Mod, hcl.Diagnostics) {
+	specs, diags := layerMods(ms)
+	if len(specs) <= 0 {
+		return nil, diags
+	}
 
-	// Merge mods and create reference for mod ID.
+	mods := make([]modpacker.Mod, len(specs))
+	refs := make(map[ModID][]int, len(specs))
+
+	// Convert mods and create reference for mod ID.
+	for i, mod := range specs {
+		mods[i] = toMod(mod)
+		content, contentDiags := modContent(mod)
+		diags = append(diags, contentDiags...)
+		mods[i].Content = content
+		id := ModIDOf(mods[i])
+		refs[id] = append(refs[id], i)
+	}
+
+	// Merge check sums into corresponding mods.
 	for _, m := range ms {
-		for i, mod := range m.Mods {
-			id := modID{
-				Method:    mod.Method,
-				File:      mod.File,
-				ProjectID: mod.ProjectID,
-				FileID:    mod.FileID,
-			}
-			mods[i] = modpacker.Mod{
-				Path:      mod.Path,
-				Method:    mod.Method,
-				Action:    mod.Action,
-				File:      mod.File,
-				ProjectID: mod.ProjectID,
-				FileID:    mod.FileID,
+		for _, check := range m.Checks {
+			diags = append(diags, vetAttrs(check.Method, check.Extra)...)
+			id := checkID(check)
+			for _, i := range refs[id] {
+				mods[i].Sums = append(mods[i].Sums, check.Sums...)
 			}
-			refs[id] = &mods[i]
 		}
 	}
 
-	// Merge check sums into corresponding mods.
+	return mods, diags
+}
+
+// layerMods applies mod and remove blocks from manifests in order.
+// The relative order of the mods is preserved, and a replaced mod
+// keeps the position of the mod it replaces.
+func layerMods(ms []hclspec.Manifest) ([]hclspec.Mod, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+
+	var mods []hclspec.Mod
+	var removed []bool
+	index := make(map[string]int)
+
 	for _, m := range ms {
-		for _, check := range m.Checks {
-			id := modID{
-				Method:    check.Method,
-				File:      check.File,
-				ProjectID: check.ProjectID,
-				FileID:    check.FileID,
+		// Paths declared in the current manifest.
+		local := make(map[string]hcl.Range, len(m.Mods)+len(m.Removes))
+
+		for _, mod := range m.Mods {
+			switch mod.Side {
+			case "", modpacker.SideBoth, modpacker.SideClient, modpacker.SideServer:
+			default:
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid mod side",
+					Detail:   fmt.Sprintf("Side %q is not one of %q, %q or %q.", mod.Side, modpacker.SideBoth, modpacker.SideClient, modpacker.SideServer),
+					Subject:  mod.DeclRange.Ptr(),
+				})
+			}
+
+			method, _ := sourceOf(mod)
+			diags = append(diags, vetAttrs(method, mod.Extra)...)
+
+			p := path.Clean(mod.Path)
+			if r, ok := local[p]; ok {
+				diags = append(diags, conflictDiag(p, mod.DeclRange, r))
+				continue
 			}
-			mm, ok := refs[id]
+			local[p] = mod.DeclRange
+
+			if i, ok := index[p]; ok {
+				mods[i] = mod
+				continue
+			}
+			index[p] = len(mods)
+			mods = append(mods, mod)
+			removed = append(removed, false)
+		}
+
+		for _, rm := range m.Removes {
+			p := path.Clean(rm.Path)
+			if r, ok := local[p]; ok {
+				diags = append(diags, conflictDiag(p, rm.DeclRange, r))
+				continue
+			}
+			local[p] = rm.DeclRange
+
+			i, ok := index[p]
 			if !ok {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Unknown mod path",
+					Detail:   fmt.Sprintf("No mod with path %q is declared in previous manifests.", p),
+					Subject:  rm.DeclRange.Ptr(),
+				})
 				continue
 			}
-			mm.Sums = append(mm.Sums, check.Sums...)
+			delete(index, p)
+			removed[i] = true
 		}
 	}
 
-	return mods
+	n := 0
+	for i, mod := range mods {
+		if removed[i] {
+			continue
+		}
+		mods[n] = mod
+		n++
+	}
+	return mods[:n], diags
+}
+
+// modContent returns the inline content of the mod.
+func modContent(mod hclspec.Mod) ([]byte, hcl.Diagnostics) {
+	switch {
+	case mod.Content != nil && mod.ContentBase64 != nil:
+		return nil, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Conflicting mod content",
+			Detail:   "Only one of content and content_base64 attributes can be set.",
+			Subject:  mod.DeclRange.Ptr(),
+		}}
+	case mod.Content != nil:
+		return []byte(*mod.Content), nil
+	case mod.ContentBase64 != nil:
+		b, err := base64.StdEncoding.DecodeString(*mod.ContentBase64)
+		if err != nil {
+			return nil, hcl.Diagnostics{{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid mod content",
+				Detail:   fmt.Sprintf("The content_base64 attribute is not valid base64: %s.", err),
+				Subject:  mod.DeclRange.Ptr(),
+			}}
+		}
+		return b, nil
+	}
+	return nil, nil
+}
+
+// vetAttrs reports extra attributes that are not declared by the method.
+func vetAttrs(method string, extra hcl.Attributes) hcl.Diagnostics {
+	if len(extra) <= 0 {
+		return nil
+	}
+	declared := make(map[string]bool)
+	if m, ok := fetcher.Lookup(method); ok {
+		if am, ok := m.(fetcher.AttrMethod); ok {
+			for _, name := range am.Attributes() {
+				declared[name] = true
+			}
+		}
+	}
+	attrs := make([]*hcl.Attribute, 0, len(extra))
+	for _, attr := range extra {
+		if declared[attr.Name] {
+			continue
+		}
+		attrs = append(attrs, attr)
+	}
+	// Attributes are stored in map, so we sort them
+	// to get deterministic diagnostics.
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].NameRange.Start.Byte < attrs[j].NameRange.Start.Byte
+	})
+	var diags hcl.Diagnostics
+	for _, attr := range attrs {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Unsupported argument",
+			Detail:   fmt.Sprintf("An argument named %q is not expected here.", attr.Name),
+			Subject:  attr.NameRange.Ptr(),
+		})
+	}
+	return diags
+}
+
+func conflictDiag(p string, subject, prev hcl.Range) *hcl.Diagnostic {
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Conflicting mod path",
+		Detail:   fmt.Sprintf("Path %q was already used in this manifest at %s.", p, prev),
+		Subject:  subject.Ptr(),
+	}
 }
@@ -0,0 +1,211 @@
+package pack
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+
+	"github.com/tie/modpacker/pack/hclspec"
+)
+
+// decodeManifests decodes manifests from native syntax sources.
+func decodeManifests(t *testing.T, srcs []string) []hclspec.Manifest {
+	t.Helper()
+	parser := hclparse.NewParser()
+	ms := make([]hclspec.Manifest, len(srcs))
+	for i, src := range srcs {
+		file, diags := parser.ParseHCL([]byte(src), fmt.Sprintf("m%d.hcl", i))
+		if diags.HasErrors() {
+			t.Fatal(diags)
+		}
+		m, diags := hclspec.DecodeManifest(file.Body, nil)
+		if diags.HasErrors() {
+			t.Fatal(diags)
+		}
+		ms[i] = m
+	}
+	return ms
+}
+
+// summaries returns the summaries of error and warning diagnostics.
+func summaries(diags hcl.Diagnostics) []string {
+	var s []string
+	for _, d := range diags {
+		prefix := "error"
+		if d.Severity == hcl.DiagWarning {
+			prefix = "warning"
+		}
+		s = append(s, prefix+": "+d.Summary)
+	}
+	return s
+}
+
+func TestModList(t *testing.T) {
+	tests := []struct {
+		Name      string
+		Manifests []string
+		// Mods are the expected mods in "path=content" form.
+		Mods  []string
+		Diags []string
+	}{
+		{
+			Name: "Replace",
+			Manifests: []string{
+				`
+mod "a" { content = "1" }
+mod "b" { content = "1" }
+mod "c" { content = "1" }
+`,
+				`
+mod "b" { content = "2" }
+mod "d" { content = "2" }
+`,
+			},
+			Mods: []string{"a=1", "b=2", "c=1", "d=2"},
+		},
+		{
+			Name: "Remove",
+			Manifests: []string{
+				`
+mod "a" { content = "1" }
+mod "b" { content = "1" }
+`,
+				`remove "a" {}`,
+			},
+			Mods: []string{"b=1"},
+		},
+		{
+			Name: "RemoveAndAdd",
+			Manifests: []string{
+				`
+mod "a" { content = "1" }
+mod "b" { content = "1" }
+`,
+				`remove "a" {}`,
+				`mod "a" { content = "3" }`,
+			},
+			Mods: []string{"b=1", "a=3"},
+		},
+		{
+			Name: "DuplicatePath",
+			Manifests: []string{
+				`
+mod "a" { content = "1" }
+mod "a" { content = "2" }
+`,
+			},
+			Mods:  []string{"a=1"},
+			Diags: []string{"error: Conflicting mod path"},
+		},
+		{
+			Name: "AddAndRemove",
+			Manifests: []string{
+				`mod "a" { content = "1" }`,
+				`
+mod "a" { content = "2" }
+remove "a" {}
+`,
+			},
+			Mods:  []string{"a=2"},
+			Diags: []string{"error: Conflicting mod path"},
+		},
+		{
+			Name: "RemoveUnknown",
+			Manifests: []string{
+				`mod "a" { content = "1" }`,
+				`remove "b" {}`,
+			},
+			Mods:  []string{"a=1"},
+			Diags: []string{"error: Unknown mod path"},
+		},
+		{
+			Name: "RemoveRemoved",
+			Manifests: []string{
+				`mod "a" { content = "1" }`,
+				`remove "a" {}`,
+				`remove "a" {}`,
+			},
+			Diags: []string{"error: Unknown mod path"},
+		},
+		{
+			Name: "CleanReplace",
+			Manifests: []string{
+				`
+mod "./a" { content = "1" }
+mod "b" { content = "1" }
+`,
+				`mod "a" { content = "2" }`,
+			},
+			Mods: []string{"a=2", "b=1"},
+		},
+		{
+			Name: "CleanRemove",
+			Manifests: []string{
+				`mod "a/b" { content = "1" }`,
+				`remove "./a//b" {}`,
+			},
+		},
+		{
+			Name: "CleanDuplicate",
+			Manifests: []string{
+				`
+mod "./a" { content = "1" }
+mod "a" { content = "2" }
+`,
+			},
+			Mods:  []string{"./a=1"},
+			Diags: []string{"error: Conflicting mod path"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			mods, diags := ModList(decodeManifests(t, tt.Manifests))
+			var got []string
+			for _, m := range mods {
+				got = append(got, m.Path+"="+string(m.Content))
+			}
+			if !reflect.DeepEqual(got, tt.Mods) {
+				t.Errorf("got mods %q, expected %q", got, tt.Mods)
+			}
+			if s := summaries(diags); !reflect.DeepEqual(s, tt.Diags) {
+				t.Errorf("got diagnostics %q, expected %q", s, tt.Diags)
+			}
+		})
+	}
+}
+
+func TestModListChecks(t *testing.T) {
+	ms := decodeManifests(t, []string{`
+mod "a.jar" {
+  method = "http"
+  file   = "https://example.com/mod.jar"
+}
+mod "b.jar" {
+  method = "http"
+  file   = "https://example.com/mod.jar"
+}
+mod "c.jar" {
+  method = "http"
+  file   = "https://example.com/other.jar"
+}
+`, `
+check {
+  method = "http"
+  file   = "https://example.com/mod.jar"
+  sums   = ["sha1:00"]
+}
+`})
+	mods, diags := ModList(ms)
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	expected := [][]string{{"sha1:00"}, {"sha1:00"}, nil}
+	for i, m := range mods {
+		if !reflect.DeepEqual(m.Sums, expected[i]) {
+			t.Errorf("mod %q: got sums %q, expected %q", m.Path, m.Sums, expected[i])
+		}
+	}
+}
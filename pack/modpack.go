@@ -0,0 +1,73 @@
+package pack
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+
+	"github.com/tie/modpacker/modpacker"
+	"github.com/tie/modpacker/pack/hclspec"
+)
+
+// Modpack merges modpack blocks from manifests. Fields set
+// in later manifests override the ones from earlier manifests.
+func Modpack(ms []hclspec.Manifest) modpacker.Modpack {
+	var mp modpacker.Modpack
+	for _, m := range ms {
+		b := m.Modpack
+		if b == nil {
+			continue
+		}
+		set(&mp.Name, b.Name)
+		set(&mp.Version, b.Version)
+		set(&mp.Author, b.Author)
+		set(&mp.Description, b.Description)
+		set(&mp.Minecraft, b.Minecraft)
+		set(&mp.Loader, b.Loader)
+	}
+	return mp
+}
+
+func set(dst *string, s string) {
+	if s == "" {
+		return
+	}
+	*dst = s
+}
+
+// CheckCurseModpack reports modpack fields that are required
+// for CurseForge modpacks but are not set in manifests.
+func CheckCurseModpack(ms []hclspec.Manifest) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+
+	// Report missing fields at the last modpack block, if any.
+	var subject *hcl.Range
+	for _, m := range ms {
+		if m.Modpack != nil {
+			subject = m.Modpack.DeclRange.Ptr()
+		}
+	}
+
+	mp := Modpack(ms)
+	fields := []struct {
+		Name  string
+		Value string
+	}{
+		{"name", mp.Name},
+		{"version", mp.Version},
+		{"minecraft", mp.Minecraft},
+		{"loader", mp.Loader},
+	}
+	for _, f := range fields {
+		if f.Value != "" {
+			continue
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Missing modpack field",
+			Detail:   fmt.Sprintf("The %q field of modpack block is required for CurseForge modpacks.", f.Name),
+			Subject:  subject,
+		})
+	}
+	return diags
+}
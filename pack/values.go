@@ -0,0 +1,61 @@
+package pack
+
+import (
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/tie/modpacker/pack/hclspec"
+)
+
+// Values merges input variables and local values from manifests and
+// converts them to Go values. Values from later manifests override the
+// ones with the same name from earlier manifests.
+func Values(ms []hclspec.Manifest) (vars, locals map[string]interface{}) {
+	vars = make(map[string]interface{})
+	locals = make(map[string]interface{})
+	for _, m := range ms {
+		for name, v := range m.Variables {
+			vars[name] = goValue(v)
+		}
+		for name, v := range m.Locals {
+			locals[name] = goValue(v)
+		}
+	}
+	return vars, locals
+}
+
+// goValue converts v to string, bool, int64, float64, []interface{}
+// or map[string]interface{}. Null and unknown values are converted to nil.
+func goValue(v cty.Value) interface{} {
+	if v.IsNull() || !v.IsKnown() {
+		return nil
+	}
+	ty := v.Type()
+	switch {
+	case ty == cty.String:
+		return v.AsString()
+	case ty == cty.Bool:
+		return v.True()
+	case ty == cty.Number:
+		f := v.AsBigFloat()
+		if i, acc := f.Int64(); acc == 0 {
+			return i
+		}
+		x, _ := f.Float64()
+		return x
+	case ty.IsListType(), ty.IsSetType(), ty.IsTupleType():
+		l := make([]interface{}, 0, v.LengthInt())
+		for it := v.ElementIterator(); it.Next(); {
+			_, ev := it.Element()
+			l = append(l, goValue(ev))
+		}
+		return l
+	case ty.IsMapType(), ty.IsObjectType():
+		m := make(map[string]interface{}, v.LengthInt())
+		for it := v.ElementIterator(); it.Next(); {
+			k, ev := it.Element()
+			m[k.AsString()] = goValue(ev)
+		}
+		return m
+	}
+	return nil
+}
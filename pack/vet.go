@@ -0,0 +1,331 @@
+package pack
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/tie/modpacker/fetcher"
+	"github.com/tie/modpacker/modpacker"
+	"github.com/tie/modpacker/pack/hclspec"
+)
+
+// Vet reports suspicious constructs in manifests that would either fail
+// or produce unexpected results on compile. Path conflicts between mod
+// blocks are reported by ModList and are not duplicated here.
+func Vet(ms []hclspec.Manifest) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+
+	mods, _ := layerMods(ms)
+
+	ids := make(map[ModID]bool, len(mods))
+	checked := make(map[ModID]bool)
+	for _, m := range ms {
+		for _, check := range m.Checks {
+			checked[checkID(check)] = true
+		}
+	}
+
+	for _, mod := range mods {
+		ids[ModIDOf(toMod(mod))] = true
+		diags = append(diags, vetPath(mod.Path, mod.DeclRange)...)
+		diags = append(diags, vetMod(mod, checked)...)
+	}
+	for _, m := range ms {
+		for _, rm := range m.Removes {
+			diags = append(diags, vetPath(rm.Path, rm.DeclRange)...)
+		}
+	}
+	diags = append(diags, vetNested(mods)...)
+
+	for _, m := range ms {
+		for _, check := range m.Checks {
+			if ids[checkID(check)] {
+				continue
+			}
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagWarning,
+				Summary:  "Unused check",
+				Detail:   "This check block does not match any mod.",
+				Subject:  check.DeclRange.Ptr(),
+			})
+		}
+	}
+
+	return diags
+}
+
+func vetPath(p string, subject hcl.Range) hcl.Diagnostics {
+	var detail string
+	switch clean := path.Clean(p); {
+	case path.IsAbs(p):
+		detail = fmt.Sprintf("Path %q is absolute. Mod paths are relative to the modpack root.", p)
+	case clean == ".." || strings.HasPrefix(clean, "../"):
+		detail = fmt.Sprintf("Path %q refers to a location outside of the modpack root.", p)
+	default:
+		return nil
+	}
+	return hcl.Diagnostics{{
+		Severity: hcl.DiagError,
+		Summary:  "Invalid mod path",
+		Detail:   detail,
+		Subject:  subject.Ptr(),
+	}}
+}
+
+func vetMod(mod hclspec.Mod, checked map[ModID]bool) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+
+	remote := false
+	method, _ := sourceOf(mod)
+	if m, ok := fetcher.Lookup(method); !ok {
+		diags = append(diags, unknownMethod(method, mod.DeclRange))
+	} else {
+		_, remote = m.(fetcher.Remote)
+		if vm, ok := m.(fetcher.VetMethod); ok {
+			for _, p := range vm.Vet(vetModOf(mod)) {
+				severity := hcl.DiagError
+				if p.Warning {
+					severity = hcl.DiagWarning
+				}
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: severity,
+					Summary:  p.Summary,
+					Detail:   p.Detail,
+					Subject:  mod.DeclRange.Ptr(),
+				})
+			}
+		}
+	}
+
+	switch mod.Action {
+	case modpacker.ActionNone:
+	case modpacker.ActionUnzip:
+	case modpacker.ActionUntar:
+	case modpacker.ActionTemplate:
+	case modpacker.ActionPatch:
+		if len(mod.Patches) <= 0 {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Missing patches",
+				Detail:   "The \"patch\" action requires patches attribute.",
+				Subject:  mod.DeclRange.Ptr(),
+			})
+		}
+	case modpacker.ActionExtract:
+		if mod.Member == "" {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Missing archive member",
+				Detail:   "The \"extract\" action requires member attribute.",
+				Subject:  mod.DeclRange.Ptr(),
+			})
+		}
+	default:
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Unknown mod action",
+			Detail:   fmt.Sprintf("Action %q is not supported.", mod.Action),
+			Subject:  mod.DeclRange.Ptr(),
+		})
+	}
+
+	if method != modpacker.MethodInline && hasContent(mod) {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagWarning,
+			Summary:  "Unused attribute",
+			Detail:   fmt.Sprintf("The content attributes are used only with \"inline\" method, not %q.", method),
+			Subject:  mod.DeclRange.Ptr(),
+		})
+	}
+
+	if mod.Action != modpacker.ActionExtract && mod.Member != "" {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagWarning,
+			Summary:  "Unused attribute",
+			Detail:   "The member attribute is used only with \"extract\" action.",
+			Subject:  mod.DeclRange.Ptr(),
+		})
+	}
+
+	extract := isExtract(mod.Action)
+	if !extract && mod.Action != modpacker.ActionPatch && len(mod.Patches) > 0 {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagWarning,
+			Summary:  "Unused attribute",
+			Detail:   "The patches attribute is used only with \"patch\" action and when extracting archives.",
+			Subject:  mod.DeclRange.Ptr(),
+		})
+	}
+	if mod.Strip < 0 {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid strip value",
+			Detail:   "The strip attribute must not be negative.",
+			Subject:  mod.DeclRange.Ptr(),
+		})
+	}
+	if !extract && (mod.Strip != 0 || mod.Subdir != "") {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagWarning,
+			Summary:  "Unused attribute",
+			Detail:   "The strip and subdir attributes are used only when extracting archives.",
+			Subject:  mod.DeclRange.Ptr(),
+		})
+	}
+	if !extract && !isDir(method) && (len(mod.Include) > 0 || len(mod.Exclude) > 0) {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagWarning,
+			Summary:  "Unused attribute",
+			Detail:   "The include and exclude attributes are used only with directories and when extracting archives.",
+			Subject:  mod.DeclRange.Ptr(),
+		})
+	}
+
+	for _, patterns := range [][]string{mod.Include, mod.Exclude} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err == nil {
+				continue
+			}
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid pattern",
+				Detail:   fmt.Sprintf("Pattern %q is malformed.", pattern),
+				Subject:  mod.DeclRange.Ptr(),
+			})
+		}
+	}
+
+	if remote && !checked[ModIDOf(toMod(mod))] {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Missing checksums",
+			Detail:   fmt.Sprintf("Mod %q is downloaded from remote source but has no check block. Use sums subcommand to generate checksums.", mod.Path),
+			Subject:  mod.DeclRange.Ptr(),
+		})
+	}
+
+	return diags
+}
+
+// vetModOf converts the mod spec for fetcher.VetMethod. Unlike toMod,
+// it keeps the file attribute as written, and the content is set if
+// either of the content attributes is.
+func vetModOf(mod hclspec.Mod) modpacker.Mod {
+	m := toMod(mod)
+	m.File = mod.File
+	if hasContent(mod) {
+		content, _ := modContent(mod)
+		if content == nil {
+			// Conflicting content attributes are reported by ModList.
+			content = []byte{}
+		}
+		m.Content = content
+	}
+	return m
+}
+
+// CheckMethods reports mods with methods that are not registered
+// in the fetcher package, and extra attributes not declared by the
+// method. Mods are the contents of mod blocks as returned by
+// hclspec.CheckSchema. Methods that refer to variables are not checked.
+func CheckMethods(mods []hclspec.ModContent) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, mod := range mods {
+		method := modpacker.MethodFile
+		_, hasContent := mod.Attributes["content"]
+		_, hasBase64 := mod.Attributes["content_base64"]
+		if hasContent || hasBase64 {
+			method = modpacker.MethodInline
+		}
+		if attr, ok := mod.Attributes["method"]; ok {
+			if len(attr.Expr.Variables()) > 0 {
+				continue
+			}
+			val, valDiags := attr.Expr.Value(nil)
+			if valDiags.HasErrors() || !val.IsKnown() || val.IsNull() || val.Type() != cty.String {
+				continue
+			}
+			method = val.AsString()
+			if _, ok := fetcher.Lookup(method); !ok {
+				diags = append(diags, unknownMethod(method, attr.Expr.Range()))
+				continue
+			}
+		}
+		diags = append(diags, vetAttrs(method, mod.Extra)...)
+	}
+	return diags
+}
+
+func unknownMethod(method string, subject hcl.Range) *hcl.Diagnostic {
+	names := fetcher.Methods()
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = fmt.Sprintf("%q", name)
+		if name == modpacker.MethodFile {
+			quoted[i] += " (local file, the default)"
+		}
+	}
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Unknown mod method",
+		Detail:   fmt.Sprintf("Method %q is not supported. Supported methods are %s.", method, strings.Join(quoted, ", ")),
+		Subject:  subject.Ptr(),
+	}
+}
+
+// vetNested reports mods with paths nested in the path of a mod that
+// is added as a single file, either as is or extracted from archive.
+// Such a path cannot be both a file and a directory in the modpack
+// archive.
+func vetNested(mods []hclspec.Mod) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+
+	files := make(map[string]hclspec.Mod)
+	for _, mod := range mods {
+		if isDir(mod.Method) || isExtract(mod.Action) {
+			continue
+		}
+		files[path.Clean(mod.Path)] = mod
+	}
+
+	for _, mod := range mods {
+		p := path.Clean(mod.Path)
+		for dir := path.Dir(p); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			f, ok := files[dir]
+			if !ok {
+				continue
+			}
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Conflicting mod path",
+				Detail:   fmt.Sprintf("Path %q is inside of %q that is a file declared at %s.", p, dir, f.DeclRange),
+				Subject:  mod.DeclRange.Ptr(),
+			})
+			break
+		}
+	}
+	return diags
+}
+
+// isDir reports whether mods with the method may be directories.
+func isDir(method string) bool {
+	m, ok := fetcher.Lookup(method)
+	if !ok {
+		return false
+	}
+	_, ok = m.(fetcher.DirMethod)
+	return ok
+}
+
+// isExtract reports whether the action extracts archive members.
+func isExtract(action string) bool {
+	switch action {
+	case modpacker.ActionUnzip, modpacker.ActionUntar:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package pack
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVet(t *testing.T) {
+	tests := []struct {
+		Name      string
+		Manifests []string
+		Diags     []string
+	}{
+		{
+			Name: "Valid",
+			Manifests: []string{`
+mod "mods/a.jar" {
+  method = "http"
+  file   = "https://example.com/a.jar"
+}
+mod "config/a.cfg" {
+  content = "a"
+}
+check {
+  method = "http"
+  file   = "https://example.com/a.jar"
+  sums   = ["sha1:00"]
+}
+`},
+		},
+		{
+			Name: "AbsolutePath",
+			Manifests: []string{
+				`mod "/a" { content = "a" }`,
+			},
+			Diags: []string{"error: Invalid mod path"},
+		},
+		{
+			Name: "EscapingPath",
+			Manifests: []string{
+				`mod "a/../../b" { content = "a" }`,
+			},
+			Diags: []string{"error: Invalid mod path"},
+		},
+		{
+			Name: "EscapingRemove",
+			Manifests: []string{
+				`remove "../b" {}`,
+			},
+			Diags: []string{"error: Invalid mod path"},
+		},
+		{
+			Name: "UnknownMethod",
+			Manifests: []string{
+				`mod "a" { method = "ftp" }`,
+			},
+			Diags: []string{"error: Unknown mod method"},
+		},
+		{
+			Name: "UnknownAction",
+			Manifests: []string{
+				`mod "a" {
+  content = "a"
+  action  = "explode"
+}`,
+			},
+			Diags: []string{"error: Unknown mod action"},
+		},
+		{
+			Name: "MissingCurseFile",
+			Manifests: []string{`
+mod "a.jar" {
+  method    = "curse"
+  projectID = 1
+}
+check {
+  method    = "curse"
+  projectID = 1
+  sums      = ["sha1:00"]
+}
+`},
+			Diags: []string{"error: Missing CurseForge file"},
+		},
+		{
+			Name: "MissingChecksums",
+			Manifests: []string{`
+mod "a.jar" {
+  method = "http"
+  file   = "https://example.com/a.jar"
+}
+`},
+			Diags: []string{"error: Missing checksums"},
+		},
+		{
+			// Local files do not require checksums.
+			Name: "LocalFile",
+			Manifests: []string{
+				`mod "a.jar" { file = "a.jar" }`,
+			},
+		},
+		{
+			Name: "UnusedCheck",
+			Manifests: []string{`
+check {
+  method = "http"
+  file   = "https://example.com/a.jar"
+  sums   = ["sha1:00"]
+}
+`},
+			Diags: []string{"warning: Unused check"},
+		},
+		{
+			// Checks of removed mods are unused.
+			Name: "RemovedCheck",
+			Manifests: []string{`
+mod "a.jar" {
+  method = "http"
+  file   = "https://example.com/a.jar"
+}
+check {
+  method = "http"
+  file   = "https://example.com/a.jar"
+  sums   = ["sha1:00"]
+}
+`, `remove "a.jar" {}`},
+			Diags: []string{"warning: Unused check"},
+		},
+		{
+			Name: "NestedPath",
+			Manifests: []string{`
+mod "a" { content = "a" }
+mod "a/b" { content = "b" }
+`},
+			Diags: []string{"error: Conflicting mod path"},
+		},
+		{
+			Name: "NestedDir",
+			Manifests: []string{`
+mod "a" {
+  method = "dir"
+  file   = "a"
+}
+mod "a/b" { content = "b" }
+`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			diags := Vet(decodeManifests(t, tt.Manifests))
+			if s := summaries(diags); !reflect.DeepEqual(s, tt.Diags) {
+				t.Errorf("got diagnostics %q, expected %q", s, tt.Diags)
+			}
+		})
+	}
+}